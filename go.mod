@@ -1,4 +1,4 @@
-module simplepdfcompress
+module github.com/thelaonerd/simplepdfcompress
 
 go 1.25.5
 
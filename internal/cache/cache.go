@@ -0,0 +1,341 @@
+package cache
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+	"github.com/thelaonerd/simplepdfcompress/internal/fileops"
+)
+
+// DefaultMaxSize is the default cap on the size of the cached outputs
+const DefaultMaxSize = 5 << 30 // 5 GB
+
+// Key identifies a compression by the content of its input and the
+// options that affect the output
+type Key struct {
+	Input   string // SHA-256 of the input file
+	Options string // SHA-256 of the relevant options
+}
+
+// id names the cached output of k
+func (k Key) id() string {
+	sum := sha256.Sum256([]byte(k.Input + ":" + k.Options))
+	return hex.EncodeToString(sum[:])
+}
+
+// Entry describes a cached output
+type Entry struct {
+	Key          Key
+	OriginalSize int64
+	OutputHash   string // SHA-256 of the output file
+	OutputSize   int64
+	Created      time.Time
+	Used         time.Time
+}
+
+// record is one line of the append-only index, replayed on Open:
+//
+//	{"op":"put","id":...,"entry":{...}}
+//	{"op":"use","id":...,"time":...}
+//	{"op":"delete","id":...}
+type record struct {
+	Op    string    `json:"op"`
+	ID    string    `json:"id"`
+	Entry *Entry    `json:"entry,omitempty"`
+	Time  time.Time `json:"time,omitzero"`
+}
+
+// Cache stores compressed outputs keyed by input content and options, so
+// unchanged files do not have to be compressed again. The least recently
+// used outputs are removed once the cache grows beyond its size cap.
+type Cache struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+	index   *os.File
+	entries map[string]*Entry
+	size    int64 // total OutputSize of entries
+}
+
+// Dir returns the default cache location in the user config dir
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "simplepdfcompress", "cache"), nil
+}
+
+// Open loads the cache in dir, creating it if needed. maxSize caps the
+// cached outputs in bytes (0 means DefaultMaxSize).
+func Open(dir string, maxSize int64) (*Cache, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "objects"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	c := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		entries: make(map[string]*Entry),
+	}
+
+	// 1. Replay the index
+	lines := 0
+	if f, err := os.Open(c.indexPath()); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines++
+			var rec record
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				continue // torn line from a crash
+			}
+			switch rec.Op {
+			case "put":
+				if rec.Entry != nil {
+					c.entries[rec.ID] = rec.Entry
+				}
+			case "use":
+				if e, ok := c.entries[rec.ID]; ok {
+					e.Used = rec.Time
+				}
+			case "delete":
+				delete(c.entries, rec.ID)
+			}
+		}
+		f.Close()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read cache index: %w", err)
+	}
+
+	// 2. Drop entries whose output has gone missing
+	for id, e := range c.entries {
+		if _, err := os.Stat(c.objectPath(id)); err != nil {
+			delete(c.entries, id)
+			continue
+		}
+		c.size += e.OutputSize
+	}
+
+	// 3. Rewrite the index when it is mostly stale records
+	if lines > 2*len(c.entries)+100 {
+		if err := c.compact(); err != nil {
+			return nil, err
+		}
+	}
+
+	index, err := os.OpenFile(c.indexPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache index: %w", err)
+	}
+	c.index = index
+	// Terminate a possibly torn last line so new records start cleanly
+	if _, err := index.Write([]byte("\n")); err != nil {
+		index.Close()
+		return nil, fmt.Errorf("failed to write cache index: %w", err)
+	}
+	return c, nil
+}
+
+// KeyFor combines the SHA-256 of an input (see compression.DigestFile)
+// with a hash of the options that affect the output (limits such as
+// Timeout or LowPriority and the file attributes do not)
+func KeyFor(inputHash string, opts compression.CompressionOptions) (Key, error) {
+	opts.Timeout = 0
+	opts.MaxMemory = 0
+	opts.LowPriority = false
+	opts.PreserveTimes, opts.PreserveMode, opts.PreserveXattrs = false, false, false
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return Key{}, err
+	}
+	sum := sha256.Sum256(data)
+	return Key{Input: inputHash, Options: hex.EncodeToString(sum[:])}, nil
+}
+
+// Restore puts the cached output for key at outputPath. An existing
+// output with the cached content is left alone. It reports false if key
+// is not cached.
+func (c *Cache) Restore(key Key, outputPath string) (Entry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := key.id()
+	e, ok := c.entries[id]
+	if !ok {
+		return Entry{}, false, nil
+	}
+
+	if hash, err := hashFile(outputPath); err != nil || hash != e.OutputHash {
+		if err := fileops.CopyFile(c.objectPath(id), outputPath); err != nil {
+			return Entry{}, false, fmt.Errorf("failed to restore cached output: %w", err)
+		}
+	}
+
+	e.Used = time.Now()
+	c.write(record{Op: "use", ID: id, Time: e.Used})
+	return *e, true, nil
+}
+
+// Store caches outputPath as the result of compressing key, then evicts
+// the least recently used outputs beyond the size cap. Outputs larger
+// than the whole cache are not stored.
+func (c *Cache) Store(key Key, outputPath string, originalSize int64) error {
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat output file: %w", err)
+	}
+	if info.Size() > c.maxSize {
+		return nil
+	}
+	outputHash, err := hashFile(outputPath)
+	if err != nil {
+		return err
+	}
+
+	// Copy outside the lock; the object name is unique per key
+	id := key.id()
+	if err := fileops.CopyFile(outputPath, c.objectPath(id)); err != nil {
+		return fmt.Errorf("failed to store output in cache: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	e := &Entry{
+		Key:          key,
+		OriginalSize: originalSize,
+		OutputHash:   outputHash,
+		OutputSize:   info.Size(),
+		Created:      now,
+		Used:         now,
+	}
+	if old, ok := c.entries[id]; ok {
+		c.size -= old.OutputSize
+	}
+	c.entries[id] = e
+	c.size += e.OutputSize
+	if err := c.write(record{Op: "put", ID: id, Entry: e}); err != nil {
+		return err
+	}
+	c.evict()
+	return nil
+}
+
+// Size returns the total size of the cached outputs
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Clear removes every cached output
+func (c *Cache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.entries {
+		os.Remove(c.objectPath(id))
+	}
+	c.entries = make(map[string]*Entry)
+	c.size = 0
+	if err := c.index.Truncate(0); err != nil {
+		return fmt.Errorf("failed to clear cache index: %w", err)
+	}
+	return nil
+}
+
+// Close closes the index file
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.index.Close()
+}
+
+// evict removes least recently used outputs until the cache fits its cap
+func (c *Cache) evict() {
+	if c.size <= c.maxSize {
+		return
+	}
+	ids := make([]string, 0, len(c.entries))
+	for id := range c.entries {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return c.entries[ids[i]].Used.Before(c.entries[ids[j]].Used)
+	})
+	for _, id := range ids {
+		if c.size <= c.maxSize {
+			break
+		}
+		c.size -= c.entries[id].OutputSize
+		delete(c.entries, id)
+		os.Remove(c.objectPath(id))
+		c.write(record{Op: "delete", ID: id})
+	}
+}
+
+// compact rewrites the index with one record per entry
+func (c *Cache) compact() error {
+	tmp := c.indexPath() + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to compact cache index: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for id, e := range c.entries {
+		enc.Encode(record{Op: "put", ID: id, Entry: e})
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to compact cache index: %w", err)
+	}
+	if err := os.Rename(tmp, c.indexPath()); err != nil {
+		return fmt.Errorf("failed to compact cache index: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) write(rec record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err := c.index.Write(data); err != nil {
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) indexPath() string {
+	return filepath.Join(c.dir, "index.jsonl")
+}
+
+func (c *Cache) objectPath(id string) string {
+	return filepath.Join(c.dir, "objects", id+".pdf")
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+)
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// keyFor returns the key of compressing the file at path with opts
+func keyFor(t *testing.T, path string, opts compression.CompressionOptions) Key {
+	t.Helper()
+	digest, err := compression.DigestFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyFor(digest.Hash, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestStoreRestore(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(filepath.Join(dir, "cache"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	input := filepath.Join(dir, "in.pdf")
+	output := filepath.Join(dir, "out.pdf")
+	writeFile(t, input, "original input")
+	writeFile(t, output, "compressed")
+	opts := compression.CompressionOptions{Quality: "ebook"}
+	key := keyFor(t, input, opts)
+	if err := c.Store(key, output, 14); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := filepath.Join(dir, "restored.pdf")
+	entry, ok, err := c.Restore(key, restored)
+	if err != nil || !ok {
+		t.Fatalf("Restore = %v, %v, want a hit", ok, err)
+	}
+	if entry.OriginalSize != 14 || entry.OutputSize != int64(len("compressed")) {
+		t.Errorf("entry sizes %d, %d, want 14, %d", entry.OriginalSize, entry.OutputSize, len("compressed"))
+	}
+	if data, _ := os.ReadFile(restored); string(data) != "compressed" {
+		t.Errorf("restored output = %q, want %q", data, "compressed")
+	}
+
+	// Limits and file attributes do not change the output
+	limited := opts
+	limited.Timeout = time.Minute
+	limited.LowPriority = true
+	limited.PreserveTimes = true
+	if _, ok, _ := c.Restore(keyFor(t, input, limited), restored); !ok {
+		t.Error("options that do not affect the output missed the cache")
+	}
+
+	screen := opts
+	screen.Quality = "screen"
+	if _, ok, _ := c.Restore(keyFor(t, input, screen), restored); ok {
+		t.Error("changed quality hit the cache")
+	}
+	writeFile(t, input, "edited input")
+	if _, ok, _ := c.Restore(keyFor(t, input, opts), restored); ok {
+		t.Error("changed input hit the cache")
+	}
+}
+
+func TestOpenReplaysIndex(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(filepath.Join(dir, "cache"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "out.pdf")
+	writeFile(t, output, "compressed")
+	key := Key{Input: "input", Options: "options"}
+	if err := c.Store(key, output, 100); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	c, err = Open(filepath.Join(dir, "cache"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Size() != int64(len("compressed")) {
+		t.Errorf("Size = %d, want %d", c.Size(), len("compressed"))
+	}
+	if _, ok, err := c.Restore(key, filepath.Join(dir, "restored.pdf")); err != nil || !ok {
+		t.Errorf("Restore after reopening = %v, %v, want a hit", ok, err)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(filepath.Join(dir, "cache"), 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	output := filepath.Join(dir, "out.pdf")
+	writeFile(t, output, "ten bytes!")
+	old, recent := Key{Input: "old"}, Key{Input: "recent"}
+	for _, key := range []Key{old, recent} {
+		if err := c.Store(key, output, 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok, _ := c.Restore(old, output); ok {
+		t.Error("least recently used output was not evicted")
+	}
+	if _, ok, _ := c.Restore(recent, output); !ok {
+		t.Error("most recent output was evicted")
+	}
+	if c.Size() != 10 {
+		t.Errorf("Size = %d, want 10", c.Size())
+	}
+}
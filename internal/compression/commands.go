@@ -20,3 +20,9 @@ func GetGhostscriptCommand() string {
 	}
 	return "gs"
 }
+
+// GetQPDFCommand returns the executable name for qpdf, which is used
+// for lossless compression and repair passes.
+func GetQPDFCommand() string {
+	return "qpdf"
+}
@@ -0,0 +1,38 @@
+package compression
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// IsTransient reports whether a compression error may go away when the
+// same job is simply run again: the process was killed from outside
+// (e.g. by the OOM killer) or the system was short on resources.
+// Errors caused by the input file itself, and hitting a configured
+// limit, are not transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if IsLimitExceeded(err) {
+		return false
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == -1 {
+		// Terminated by a signal rather than exiting on its own
+		return true
+	}
+
+	for _, errno := range []syscall.Errno{syscall.EAGAIN, syscall.ENOMEM, syscall.EMFILE, syscall.ENFILE, syscall.EBUSY} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+
+	// Ghostscript ran out of memory
+	return strings.Contains(err.Error(), "VMerror")
+}
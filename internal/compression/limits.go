@@ -0,0 +1,61 @@
+package compression
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Errors returned when a job hits one of its resource limits
+var (
+	ErrTimeout     = errors.New("compression timed out")
+	ErrMemoryLimit = errors.New("compression exceeded memory limit")
+)
+
+// lowPriorityNice is the niceness given to processes started with LowPriority
+const lowPriorityNice = 10
+
+// memoryArgs caps Ghostscript's band and bitmap buffers to a quarter of
+// maxMemory each, leaving the rest for the interpreter itself
+func memoryArgs(maxMemory int64) []string {
+	return []string{
+		fmt.Sprintf("-dMaxBitmap=%d", maxMemory/4),
+		fmt.Sprintf("-dBufferSpace=%d", maxMemory/4),
+	}
+}
+
+// limitError turns a failed run into ErrTimeout or ErrMemoryLimit when
+// one of the limits in opts was the cause, and returns nil otherwise,
+// including when ctx was cancelled
+func limitError(ctx context.Context, opts CompressionOptions, err error, output string) error {
+	if opts.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s", ErrTimeout, opts.Timeout)
+	}
+	// A process killed because the job was cancelled also exits with -1;
+	// the caller reports the cancellation instead
+	if ctx.Err() != nil || opts.MaxMemory <= 0 {
+		return nil
+	}
+	// Out of address space: Ghostscript reports a VMerror, or the
+	// process dies on a failed allocation
+	var exitErr *exec.ExitError
+	killed := errors.As(err, &exitErr) && exitErr.ExitCode() == -1
+	if killed || strings.Contains(output, "VMerror") {
+		return fmt.Errorf("%w of %s", ErrMemoryLimit, formatLimit(opts.MaxMemory))
+	}
+	return nil
+}
+
+// IsLimitExceeded reports whether err was caused by a timeout or memory limit
+func IsLimitExceeded(err error) bool {
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrMemoryLimit)
+}
+
+func formatLimit(bytes int64) string {
+	if bytes >= 1<<30 && bytes%(1<<30) == 0 {
+		return fmt.Sprintf("%d GB", bytes>>30)
+	}
+	return fmt.Sprintf("%d MB", bytes>>20)
+}
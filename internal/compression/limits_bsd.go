@@ -0,0 +1,20 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package compression
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func prepareLimits(cmd *exec.Cmd, opts CompressionOptions) {}
+
+// applyLimits lowers the CPU priority of a started process. There is no
+// way to set another process's rlimits here, so MaxMemory only caps
+// Ghostscript's buffers.
+func applyLimits(p *os.Process, opts CompressionOptions) {
+	if opts.LowPriority {
+		syscall.Setpriority(syscall.PRIO_PROCESS, p.Pid, lowPriorityNice)
+	}
+}
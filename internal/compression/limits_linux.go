@@ -0,0 +1,46 @@
+//go:build linux
+
+package compression
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// ioprio_set(2) arguments: lowest priority of the best-effort class
+const (
+	ioprioWhoProcess = 1
+	ioprioClassBE    = 2
+	ioprioClassShift = 13
+	ioprioLowest     = 7
+)
+
+// prepareLimits caps the address space of the process with RLIMIT_AS.
+// Go cannot set rlimits between fork and exec, so the command is started
+// through sh, which lowers the limit and then execs the real program in
+// the same process. Without a shell MaxMemory only caps Ghostscript's
+// buffers.
+func prepareLimits(cmd *exec.Cmd, opts CompressionOptions) {
+	if opts.MaxMemory <= 0 {
+		return
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		return
+	}
+	script := fmt.Sprintf(`ulimit -v %d 2>/dev/null; exec "$0" "$@"`, opts.MaxMemory>>10)
+	cmd.Args = append([]string{"sh", "-c", script, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = sh
+}
+
+// applyLimits lowers the CPU and IO priority of a started process.
+// Priorities are best effort: a failure to apply one does not stop the job.
+func applyLimits(p *os.Process, opts CompressionOptions) {
+	if opts.LowPriority {
+		syscall.Setpriority(syscall.PRIO_PROCESS, p.Pid, lowPriorityNice)
+		syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(p.Pid),
+			ioprioClassBE<<ioprioClassShift|ioprioLowest)
+	}
+}
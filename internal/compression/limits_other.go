@@ -0,0 +1,14 @@
+//go:build !linux && !windows && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package compression
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Process priority and rlimits are not supported on this platform
+
+func prepareLimits(cmd *exec.Cmd, opts CompressionOptions) {}
+
+func applyLimits(p *os.Process, opts CompressionOptions) {}
@@ -0,0 +1,59 @@
+package compression
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// killedError returns the error of a process that was killed, which
+// exits with -1 like one that failed an allocation under the memory limit
+func killedError(t *testing.T) error {
+	t.Helper()
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip(err)
+	}
+	cmd := exec.Command(sleep, "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Process.Kill()
+	return cmd.Wait()
+}
+
+func TestLimitError(t *testing.T) {
+	killed := killedError(t)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithTimeout(context.Background(), -time.Second)
+	defer cancelExpired()
+
+	limits := CompressionOptions{Timeout: time.Minute, MaxMemory: 512 << 20}
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		opts   CompressionOptions
+		err    error
+		output string
+		want   error
+	}{
+		{"killed under memory limit", context.Background(), limits, killed, "", ErrMemoryLimit},
+		{"VMerror in output", context.Background(), limits, errors.New("exit status 1"), "Error: /VMerror in --run--", ErrMemoryLimit},
+		{"cancelled", cancelled, limits, killed, "", nil},
+		{"timed out", expired, limits, killed, "", ErrTimeout},
+		{"caller deadline without timeout", expired, CompressionOptions{MaxMemory: 512 << 20}, killed, "", nil},
+		{"no memory limit", context.Background(), CompressionOptions{}, killed, "", nil},
+		{"other failure", context.Background(), limits, errors.New("exit status 1"), "Error: /undefined", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := limitError(tt.ctx, tt.opts, tt.err, tt.output)
+			if tt.want == nil && got != nil || tt.want != nil && !errors.Is(got, tt.want) {
+				t.Errorf("limitError = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
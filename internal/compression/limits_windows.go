@@ -0,0 +1,25 @@
+//go:build windows
+
+package compression
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// belowNormalPriorityClass is the CreateProcess flag for low CPU priority
+const belowNormalPriorityClass = 0x00004000
+
+// prepareLimits starts the process with below-normal priority
+func prepareLimits(cmd *exec.Cmd, opts CompressionOptions) {
+	if !opts.LowPriority {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= belowNormalPriorityClass
+}
+
+func applyLimits(p *os.Process, opts CompressionOptions) {}
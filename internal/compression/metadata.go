@@ -0,0 +1,243 @@
+package compression
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// MetadataMode decides what happens to the input's document info and XMP
+// metadata in Ghostscript outputs. qpdf outputs keep them unchanged.
+type MetadataMode int
+
+const (
+	// MetadataDefault keeps what Ghostscript keeps: the Info entries
+	// except Producer and the dates, with XMP rebuilt from them
+	MetadataDefault MetadataMode = iota
+	// MetadataPreserve also keeps the input's Producer, Creator and dates.
+	// Only the Info dictionary is copied: XMP is rebuilt from it, so XMP
+	// properties without an Info entry are lost.
+	MetadataPreserve
+	// MetadataStrip removes the Info entries, XMP and the document ID
+	MetadataStrip
+)
+
+func (m MetadataMode) String() string {
+	switch m {
+	case MetadataDefault:
+		return "default"
+	case MetadataPreserve:
+		return "preserve"
+	case MetadataStrip:
+		return "strip"
+	}
+	return "unknown"
+}
+
+// infoKeys are the Info entries blanked by MetadataStrip
+var infoKeys = []string{"/Title", "/Author", "/Subject", "/Keywords", "/Creator", "/Producer"}
+
+// preservedKeys are the Info entries Ghostscript replaces, which
+// MetadataPreserve copies back from the input
+var preservedKeys = []string{"/Producer", "/Creator", "/CreationDate", "/ModDate"}
+
+// RewritesMetadata reports whether opts strip or edit the metadata,
+// which only Ghostscript outputs support
+func (o CompressionOptions) RewritesMetadata() bool {
+	return o.Metadata == MetadataStrip || o.Title != "" || o.Author != "" || o.Subject != "" || o.Keywords != ""
+}
+
+// metadataArgs returns the pdfwrite switches for opts.Metadata
+func metadataArgs(opts CompressionOptions) []string {
+	if opts.Metadata != MetadataStrip {
+		return nil
+	}
+	return []string{"-dOmitXMP", "-dOmitID", "-dOmitInfoDate"}
+}
+
+// editPdfmark returns the PostScript that sets the Info entries given in
+// opts, or "" if none are
+func editPdfmark(opts CompressionOptions) string {
+	var b strings.Builder
+	for _, e := range []struct{ key, value string }{
+		{"/Title", opts.Title},
+		{"/Author", opts.Author},
+		{"/Subject", opts.Subject},
+		{"/Keywords", opts.Keywords},
+	} {
+		if e.value != "" {
+			fmt.Fprintf(&b, " %s %s", e.key, pdfTextString(e.value))
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "[" + b.String() + " /DOCINFO pdfmark"
+}
+
+// stripPdfmark blanks the Info entries the input passes on to the output
+func stripPdfmark() string {
+	var b strings.Builder
+	b.WriteString("[")
+	for _, key := range infoKeys {
+		b.WriteString(" " + key + " ()")
+	}
+	b.WriteString(" /DOCINFO pdfmark")
+	return b.String()
+}
+
+// preservePdfmark returns the PostScript that writes the input's own
+// Producer, Creator and dates back. The strings keep their bytes but are
+// written in hex: UTF-16 text contains NUL bytes, which cannot be passed
+// on the command line, and other bytes may not survive its encoding.
+func preservePdfmark(inputPath string) (string, error) {
+	chunks, err := readInfoChunks(inputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read document info: %w", err)
+	}
+	var b strings.Builder
+	for _, key := range preservedKeys {
+		for _, buf := range chunks {
+			if token, ok := infoToken(buf, key); ok {
+				fmt.Fprintf(&b, " %s <%X>", key, stringBytes(token))
+				break
+			}
+		}
+	}
+	if b.Len() == 0 {
+		return "", nil
+	}
+	return "[" + b.String() + " /DOCINFO pdfmark", nil
+}
+
+// maxInfoToken caps the length of a string copied by infoToken
+const maxInfoToken = 64 << 10
+
+// infoToken returns the string that follows the last occurrence of key
+// in data as it is written, with delimiters (see stringBytes).
+func infoToken(data []byte, key string) (string, bool) {
+	for end := len(data); ; {
+		i := bytes.LastIndex(data[:end], []byte(key))
+		if i < 0 {
+			return "", false
+		}
+		end = i
+		rest := data[i+len(key):]
+		// A longer name such as /CreatorTool
+		if len(rest) == 0 || !bytes.ContainsAny(rest[:1], " \t\r\n(<") {
+			continue
+		}
+		rest = bytes.TrimLeft(rest, " \t\r\n")
+		if token, ok := stringToken(rest); ok {
+			return token, true
+		}
+	}
+}
+
+// stringToken returns the literal or hex string data starts with
+func stringToken(data []byte) (string, bool) {
+	if len(data) > maxInfoToken {
+		data = data[:maxInfoToken]
+	}
+	if len(data) < 2 {
+		return "", false
+	}
+	switch data[0] {
+	case '(':
+		depth := 0
+		for i := 0; i < len(data); i++ {
+			switch data[i] {
+			case '\\':
+				i++
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					return string(data[:i+1]), true
+				}
+			}
+		}
+	case '<':
+		end := bytes.IndexByte(data, '>')
+		if end < 0 {
+			return "", false
+		}
+		for _, c := range data[1:end] {
+			if !strings.ContainsRune("0123456789abcdefABCDEF \t\r\n", rune(c)) {
+				return "", false // also rejects dictionaries
+			}
+		}
+		return string(data[:end+1]), true
+	}
+	return "", false
+}
+
+// stringBytes decodes a literal or hex string token returned by
+// stringToken to the bytes it stands for
+func stringBytes(token string) []byte {
+	body := token[1 : len(token)-1]
+	if token[0] == '<' {
+		digits := make([]byte, 0, len(body)+1)
+		for i := 0; i < len(body); i++ {
+			if !strings.ContainsRune(" \t\r\n", rune(body[i])) {
+				digits = append(digits, body[i])
+			}
+		}
+		// A missing last digit is 0
+		if len(digits)%2 == 1 {
+			digits = append(digits, '0')
+		}
+		decoded, _ := hex.DecodeString(string(digits))
+		return decoded
+	}
+
+	var out []byte
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '\r':
+			// Any end of line in a literal string is read as \n
+			out = append(out, '\n')
+			if i+1 < len(body) && body[i+1] == '\n' {
+				i++
+			}
+		case c != '\\' || i+1 == len(body):
+			out = append(out, c)
+		default:
+			i++
+			switch c = body[i]; c {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				// Line continuation
+				if i+1 < len(body) && body[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				// Up to three octal digits
+				v := c - '0'
+				for n := 1; n < 3 && i+1 < len(body) && body[i+1] >= '0' && body[i+1] <= '7'; n++ {
+					i++
+					v = v<<3 | (body[i] - '0')
+				}
+				out = append(out, v)
+			default:
+				// \(, \) and \\, and a backslash before any other character
+				// is ignored
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
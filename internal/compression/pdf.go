@@ -1,25 +1,118 @@
 package compression
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"time"
 )
 
+// Engines that can produce the output file
+const (
+	EngineGhostscript = "gs"   // lossy recompression via pdfwrite (default)
+	EngineQPDF        = "qpdf" // lossless stream/object recompression
+)
+
+// DefaultCompatibilityLevel is the PDF version written by Ghostscript
+const DefaultCompatibilityLevel = "1.4"
+
 // CompressionOptions holds configuration for the compression job
 type CompressionOptions struct {
 	Quality string // e.g. /screen, /ebook, /printer, /prepress, /default
+
+	// Engine selects the tool used for compression ("" means Ghostscript)
+	Engine string `json:",omitempty"`
+	// CompatibilityLevel is the output PDF version ("" means 1.4)
+	CompatibilityLevel string `json:",omitempty"`
+	// NoDuplicateImageDetection disables -dDetectDuplicateImages, which
+	// some malformed files trip over
+	NoDuplicateImageDetection bool `json:",omitempty"`
+	// Repair rewrites the input with qpdf before compressing it
+	Repair bool `json:",omitempty"`
+	// FirstPage and LastPage limit Ghostscript to a page range (0 = all)
+	FirstPage int `json:",omitempty"`
+	LastPage  int `json:",omitempty"`
+
+	// Timeout kills the job if it runs longer (0 = no limit)
+	Timeout time.Duration `json:",omitempty"`
+	// MaxMemory caps Ghostscript's buffers and, on Linux, the address
+	// space of the process in bytes (0 = no limit)
+	MaxMemory int64 `json:",omitempty"`
+	// LowPriority runs the tools with lower CPU and IO priority
+	LowPriority bool `json:",omitempty"`
+	// Stamp writes provenance (see Stamp) into Ghostscript outputs
+	Stamp bool `json:",omitempty"`
+	// SourceHash is the input's SHA-256 if the caller has it already (see
+	// DigestFile); the stamp then does not read the input again
+	SourceHash string `json:"-"`
+
+	// PreserveTimes, PreserveMode and PreserveXattrs copy the input's
+	// modification and access time, permission bits and (on Linux)
+	// extended attributes and ACLs to the output
+	PreserveTimes  bool `json:",omitempty"`
+	PreserveMode   bool `json:",omitempty"`
+	PreserveXattrs bool `json:",omitempty"`
+	// PreserveDates keeps the input's CreationDate and ModDate in the
+	// document info of Ghostscript outputs (qpdf keeps them anyway)
+	PreserveDates bool `json:",omitempty"`
+
+	// Metadata decides what happens to the input's document info and XMP
+	// metadata in Ghostscript outputs
+	Metadata MetadataMode `json:",omitempty"`
+	// Title, Author, Subject and Keywords replace the document info
+	// entries of Ghostscript outputs when set
+	Title    string `json:",omitempty"`
+	Author   string `json:",omitempty"`
+	Subject  string `json:",omitempty"`
+	Keywords string `json:",omitempty"`
+
+	// Sanitize removes active and hidden content from Ghostscript outputs
+	Sanitize Sanitize `json:",omitzero"`
+}
+
+// RequiresGhostscript reports whether opts ask for changes only
+// Ghostscript outputs support
+func (o CompressionOptions) RequiresGhostscript() bool {
+	return o.RewritesMetadata() || o.Sanitize.Enabled()
+}
+
+// Hooks lets callers observe a running compression
+type Hooks struct {
+	// OnStart is called with the Ghostscript process right after it starts
+	OnStart func(p *os.Process)
+	// OnPage is called as Ghostscript finishes each page. total is 0
+	// if the page count is not known yet.
+	OnPage func(page, total int)
 }
 
 // CompressPDF compresses a single PDF file using ps2pdf
 func CompressPDF(inputPath, outputPath string, opts CompressionOptions) (int64, int64, error) {
+	return CompressPDFContext(context.Background(), inputPath, outputPath, opts)
+}
+
+// CompressPDFContext is like CompressPDF but kills Ghostscript when ctx is cancelled
+func CompressPDFContext(ctx context.Context, inputPath, outputPath string, opts CompressionOptions) (int64, int64, error) {
+	return CompressPDFWithHooks(ctx, inputPath, outputPath, opts, Hooks{})
+}
+
+// CompressPDFWithHooks is like CompressPDFContext and reports progress through hooks
+func CompressPDFWithHooks(ctx context.Context, inputPath, outputPath string, opts CompressionOptions, hooks Hooks) (int64, int64, error) {
 	// 1. Get initial file size
 	info, err := os.Stat(inputPath)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to stat input file: %w", err)
 	}
 	initialSize := info.Size()
+	inputInfo := info
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
 
 	// 2. Ensure output directory exists
 	outputDir := filepath.Dir(outputPath)
@@ -27,37 +120,152 @@ func CompressPDF(inputPath, outputPath string, opts CompressionOptions) (int64,
 		return initialSize, 0, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// 3. Construct Ghostscript command
+	// 3. Optional repair pass into a temporary file next to the output
+	source := inputPath
+	if opts.Repair {
+		repaired, err := repairPDF(ctx, inputPath, outputDir, opts)
+		if err != nil {
+			return initialSize, 0, err
+		}
+		defer os.Remove(repaired)
+		source = repaired
+	}
+
+	// 4. Execute blocking command
+	var cmd *exec.Cmd
+	switch opts.Engine {
+	case "", EngineGhostscript:
+		cmd = ghostscriptCommand(ctx, source, outputPath, opts, hooks.OnPage == nil)
+		marks, err := docinfoMarks(inputPath, opts)
+		if err != nil {
+			return initialSize, 0, err
+		}
+		if marks != "" {
+			// Runs after the input, so it overrides the input's Info entries
+			cmd.Args = append(cmd.Args, "-c", marks)
+		}
+	case EngineQPDF:
+		if opts.RequiresGhostscript() {
+			return initialSize, 0, fmt.Errorf("sanitizing, stripping or editing metadata requires the Ghostscript engine")
+		}
+		cmd = qpdfCompressCommand(ctx, source, outputPath)
+	default:
+		return initialSize, 0, fmt.Errorf("unknown compression engine %q", opts.Engine)
+	}
+	if err := runCommand(ctx, cmd, opts, hooks); err != nil {
+		return initialSize, 0, err
+	}
+
+	// 5. Get final file size
+	info, err = os.Stat(outputPath)
+	if err != nil {
+		return initialSize, 0, fmt.Errorf("failed to stat output file: %w", err)
+	}
+	finalSize := info.Size()
+
+	if err := PreserveAttributes(inputPath, outputPath, inputInfo, opts); err != nil {
+		return initialSize, finalSize, err
+	}
+
+	return initialSize, finalSize, nil
+}
+
+// ghostscriptCommand builds the pdfwrite invocation for opts
+func ghostscriptCommand(ctx context.Context, inputPath, outputPath string, opts CompressionOptions, quiet bool) *exec.Cmd {
 	// We call gs directly for better cross-platform support (windows differs from linux/mac)
 	bin := GetGhostscriptCommand()
+
+	level := opts.CompatibilityLevel
+	if level == "" {
+		level = DefaultCompatibilityLevel
+	}
+
 	args := []string{
 		"-sDEVICE=pdfwrite",
-		"-dCompatibilityLevel=1.4",
+		fmt.Sprintf("-dCompatibilityLevel=%s", level),
 		"-dNOPAUSE",
-		"-dQUIET",
 		"-dBATCH",
 		fmt.Sprintf("-sOutputFile=%s", outputPath),
 	}
+	// Page messages are only printed without -dQUIET
+	if quiet {
+		args = append(args, "-dQUIET")
+	}
+
+	args = append(args, settingsArgs(opts)...)
+	if defs := annotationDefs(opts); defs != "" {
+		args = append(args, "-c", defs, "-f")
+	}
+	args = append(args, inputPath)
 
+	return exec.CommandContext(ctx, bin, args...)
+}
+
+// settingsArgs returns the Ghostscript switches for the quality and
+// compatibility settings in opts
+func settingsArgs(opts CompressionOptions) []string {
+	var args []string
 	if opts.Quality != "" {
 		// Ghostscript requires / prefix for string constants like /ebook
 		args = append(args, fmt.Sprintf("-dPDFSETTINGS=/%s", opts.Quality))
 	}
-	args = append(args, inputPath)
+	if opts.NoDuplicateImageDetection {
+		args = append(args, "-dDetectDuplicateImages=false")
+	}
+	if opts.FirstPage > 0 {
+		args = append(args, fmt.Sprintf("-dFirstPage=%d", opts.FirstPage))
+	}
+	if opts.LastPage > 0 {
+		args = append(args, fmt.Sprintf("-dLastPage=%d", opts.LastPage))
+	}
+	if opts.MaxMemory > 0 {
+		args = append(args, memoryArgs(opts.MaxMemory)...)
+	}
+	args = append(args, metadataArgs(opts)...)
+	args = append(args, sanitizeArgs(opts)...)
+	return args
+}
 
-	cmd := exec.Command(bin, args...)
+// runCommand runs cmd to completion under the limits in opts, wiring up
+// hooks and turning failures into errors that carry the tool's output.
+func runCommand(ctx context.Context, cmd *exec.Cmd, opts CompressionOptions, hooks Hooks) error {
+	name := filepath.Base(cmd.Path)
+	program := cmd.Args[0]
 
-	// 4. Execute blocking command
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return initialSize, 0, fmt.Errorf("ps2pdf failed: %v, output: %s", err, string(output))
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	var pages *pageWriter
+	if hooks.OnPage != nil {
+		shared := &lockedWriter{w: &output}
+		pages = &pageWriter{w: shared, onPage: hooks.OnPage}
+		cmd.Stdout = pages
+		cmd.Stderr = shared
 	}
 
-	// 5. Get final file size
-	info, err = os.Stat(outputPath)
+	prepareLimits(cmd, opts)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s failed: %w, output: %s", name, err, output.String())
+	}
+	applyLimits(cmd.Process, opts)
+	if hooks.OnStart != nil {
+		hooks.OnStart(cmd.Process)
+	}
+	err := cmd.Wait()
+	if pages != nil {
+		pages.flush()
+	}
 	if err != nil {
-		return initialSize, 0, fmt.Errorf("failed to stat output file: %w", err)
+		if limitErr := limitError(ctx, opts, err, output.String()); limitErr != nil {
+			return fmt.Errorf("%s: %w", name, limitErr)
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("compression cancelled: %w", ctx.Err())
+		}
+		if isQPDFWarning(program, err) {
+			return nil
+		}
+		return fmt.Errorf("%s failed: %w, output: %s", name, err, output.String())
 	}
-	finalSize := info.Size()
-
-	return initialSize, finalSize, nil
+	return nil
 }
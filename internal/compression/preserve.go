@@ -0,0 +1,158 @@
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/fileops"
+)
+
+// InfoDates are the dates in a PDF's document info, as PDF date strings
+// such as "D:20240131120000+01'00'"
+type InfoDates struct {
+	Created  string
+	Modified string
+}
+
+// ReadInfoDates returns the CreationDate and ModDate of the PDF at path.
+// Like ReadStamp it only searches the start and end of large files, where
+// the Info dictionary usually is; dates it cannot find are left empty.
+func ReadInfoDates(path string) (InfoDates, error) {
+	chunks, err := readInfoChunks(path)
+	if err != nil {
+		return InfoDates{}, err
+	}
+	var dates InfoDates
+	for _, buf := range chunks {
+		if dates.Created == "" {
+			dates.Created = infoDate(buf, "/CreationDate")
+		}
+		if dates.Modified == "" {
+			dates.Modified = infoDate(buf, "/ModDate")
+		}
+	}
+	return dates, nil
+}
+
+// readInfoChunks returns the end and the start of the file at path, where
+// the Info dictionary usually is. The end comes first, as incremental
+// updates append newer entries there.
+func readInfoChunks(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var chunks [][]byte
+	for _, offset := range []int64{info.Size() - stampScanLimit, 0} {
+		if offset < 0 {
+			offset = 0
+		}
+		buf := make([]byte, min(info.Size()-offset, stampScanLimit))
+		if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		chunks = append(chunks, buf)
+		if offset == 0 {
+			break
+		}
+	}
+	return chunks, nil
+}
+
+// infoDate returns the date following key in data, or "" if there is
+// none or it does not look like a PDF date
+func infoDate(data []byte, key string) string {
+	value, ok := infoString(data, key)
+	if !ok || value == "" {
+		return ""
+	}
+	if strings.Trim(value, "D:0123456789+-Z'") != "" {
+		return ""
+	}
+	return value
+}
+
+// pdfmark returns the PostScript that writes the dates into the Info
+// dictionary, or "" if there are none
+func (d InfoDates) pdfmark() string {
+	var b bytes.Buffer
+	if d.Created != "" {
+		fmt.Fprintf(&b, " /CreationDate (%s)", escapePSString(d.Created))
+	}
+	if d.Modified != "" {
+		fmt.Fprintf(&b, " /ModDate (%s)", escapePSString(d.Modified))
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "[" + b.String() + " /DOCINFO pdfmark"
+}
+
+// docinfoMarks returns the pdfmarks Ghostscript runs after inputPath to
+// write the stamp, the preserved or stripped metadata and the edited
+// entries that opts ask for. Stripped outputs are not stamped, so they
+// carry nothing that links them to their source. Preserved outputs are,
+// but keep the input's Producer, which is written after the stamp.
+func docinfoMarks(inputPath string, opts CompressionOptions) (string, error) {
+	var marks []string
+	if opts.Stamp && opts.Metadata != MetadataStrip {
+		stamp, err := NewStamp(inputPath, opts)
+		if err != nil {
+			return "", err
+		}
+		marks = append(marks, stamp.pdfmark())
+	}
+	switch opts.Metadata {
+	case MetadataStrip:
+		marks = append(marks, stripPdfmark())
+	case MetadataPreserve:
+		mark, err := preservePdfmark(inputPath)
+		if err != nil {
+			return "", err
+		}
+		if mark != "" {
+			marks = append(marks, mark)
+		}
+	default:
+		if opts.PreserveDates {
+			dates, err := ReadInfoDates(inputPath)
+			if err != nil {
+				return "", fmt.Errorf("failed to read document dates: %w", err)
+			}
+			if mark := dates.pdfmark(); mark != "" {
+				marks = append(marks, mark)
+			}
+		}
+	}
+	if mark := editPdfmark(opts); mark != "" {
+		marks = append(marks, mark)
+	}
+	return strings.Join(marks, " "), nil
+}
+
+// PreserveAttributes copies the file attributes of inputPath that opts
+// ask for to outputPath. info is the input's FileInfo from before it was
+// read, see fileops.CopyAttributes.
+func PreserveAttributes(inputPath, outputPath string, info os.FileInfo, opts CompressionOptions) error {
+	which := fileops.Attributes{
+		Times:  opts.PreserveTimes,
+		Mode:   opts.PreserveMode,
+		Xattrs: opts.PreserveXattrs,
+	}
+	if which == (fileops.Attributes{}) {
+		return nil
+	}
+	if err := fileops.CopyAttributes(inputPath, outputPath, info, which); err != nil {
+		return fmt.Errorf("failed to preserve file attributes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+var (
+	// Ghostscript prints these to stdout unless -dQUIET is given
+	pageRangeRe = regexp.MustCompile(`^Processing pages (\d+) through (\d+)\.`)
+	pageRe      = regexp.MustCompile(`^Page (\d+)`)
+)
+
+// pageWriter reports "Page N" lines to onPage and forwards every other
+// line to w, so error messages are not buried under progress output.
+// total is 0 until Ghostscript announces the page range.
+type pageWriter struct {
+	w      io.Writer
+	onPage func(page, total int)
+	first  int
+	total  int
+	line   []byte
+}
+
+func (pw *pageWriter) Write(p []byte) (int, error) {
+	pw.line = append(pw.line, p...)
+	for {
+		i := bytes.IndexByte(pw.line, '\n')
+		if i < 0 {
+			break
+		}
+		if !pw.parse(bytes.TrimSpace(pw.line[:i])) {
+			if _, err := pw.w.Write(pw.line[:i+1]); err != nil {
+				return 0, err
+			}
+		}
+		pw.line = pw.line[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush forwards an unterminated last line
+func (pw *pageWriter) flush() {
+	if len(pw.line) > 0 && !pw.parse(bytes.TrimSpace(pw.line)) {
+		pw.w.Write(pw.line)
+	}
+	pw.line = nil
+}
+
+// parse reports whether line was a progress message
+func (pw *pageWriter) parse(line []byte) bool {
+	if m := pageRangeRe.FindSubmatch(line); m != nil {
+		first, _ := strconv.Atoi(string(m[1]))
+		last, _ := strconv.Atoi(string(m[2]))
+		pw.first = first
+		pw.total = last - first + 1
+		return true
+	}
+	if m := pageRe.FindSubmatch(line); m != nil {
+		page, _ := strconv.Atoi(string(m[1]))
+		// Report pages relative to the processed range
+		if pw.first > 1 {
+			page = page - pw.first + 1
+		}
+		pw.onPage(page, pw.total)
+		return true
+	}
+	return false
+}
+
+// lockedWriter serialises writes from Ghostscript's stdout and stderr
+// copiers when they are different writers.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
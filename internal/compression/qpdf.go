@@ -0,0 +1,53 @@
+package compression
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// qpdf exits with status 3 when it succeeded but printed warnings,
+// which is common for the damaged files we want it to repair.
+const qpdfExitWarnings = 3
+
+func qpdfCompressCommand(ctx context.Context, inputPath, outputPath string) *exec.Cmd {
+	return exec.CommandContext(ctx, GetQPDFCommand(),
+		"--object-streams=generate",
+		"--compress-streams=y",
+		"--recompress-flate",
+		"--compression-level=9",
+		inputPath, outputPath,
+	)
+}
+
+// repairPDF rewrites inputPath with qpdf, which reconstructs broken
+// cross-reference tables and streams. It returns a temporary file in dir.
+func repairPDF(ctx context.Context, inputPath, dir string, opts CompressionOptions) (string, error) {
+	if _, err := exec.LookPath(GetQPDFCommand()); err != nil {
+		return "", fmt.Errorf("repair pass requires qpdf: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".spc-repair-*.pdf")
+	if err != nil {
+		return "", fmt.Errorf("failed to create repair file: %w", err)
+	}
+	tmp.Close()
+
+	cmd := exec.CommandContext(ctx, GetQPDFCommand(), inputPath, tmp.Name())
+	if err := runCommand(ctx, cmd, opts, Hooks{}); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("repair pass: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+func isQPDFWarning(program string, err error) bool {
+	return program == GetQPDFCommand() && isExitCode(err, qpdfExitWarnings)
+}
+
+func isExitCode(err error, code int) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == code
+}
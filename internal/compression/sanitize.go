@@ -0,0 +1,313 @@
+package compression
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// FormAction is what Sanitize does with form fields
+type FormAction int
+
+const (
+	FormsKeep    FormAction = iota // leave forms fillable
+	FormsRemove                    // drop the fields and their contents
+	FormsFlatten                   // print the filled-in fields into the page
+)
+
+// Sanitize selects active and hidden content Ghostscript leaves out of
+// the output. The zero value removes nothing.
+//
+// JavaScript only removes the open action and the document's named
+// scripts: Ghostscript copies the actions of links and form fields it
+// keeps, scripts included. Those go only with Annotations or Forms, and
+// RemovedContent reports the ones left.
+type Sanitize struct {
+	JavaScript    bool // scripts and actions run when the document opens
+	EmbeddedFiles bool // attachments, including file attachment annotations
+	Forms         FormAction
+	Annotations   bool // comments, highlights, links and other markup
+	Thumbnails    bool // page thumbnail images
+}
+
+// Enabled reports whether s removes anything
+func (s Sanitize) Enabled() bool {
+	return s != Sanitize{}
+}
+
+// Targets returns the counts in c of the content s removes
+func (s Sanitize) Targets(c ActiveContent) ActiveContent {
+	var t ActiveContent
+	if s.JavaScript {
+		t.JavaScript, t.OpenActions = c.JavaScript, c.OpenActions
+	}
+	if s.EmbeddedFiles {
+		t.EmbeddedFiles = c.EmbeddedFiles
+	}
+	if s.Forms != FormsKeep {
+		t.FormFields = c.FormFields
+	}
+	if s.Annotations {
+		t.Annotations = c.Annotations
+	}
+	if s.Thumbnails {
+		t.Thumbnails = c.Thumbnails
+	}
+	return t
+}
+
+// annotationTypes are the non-form annotation subtypes of PDF 2.0
+var annotationTypes = []string{
+	"Text", "Link", "FreeText", "Line", "Square", "Circle", "Polygon", "PolyLine",
+	"Highlight", "Underline", "Squiggly", "StrikeOut", "Caret", "Stamp", "Ink",
+	"Popup", "FileAttachment", "Sound", "Movie", "Screen", "PrinterMark",
+	"TrapNet", "Watermark", "3D", "Redact", "Projection", "RichMedia",
+}
+
+// sanitizeArgs returns the pdfwrite switches for opts.Sanitize. Ghostscript
+// never copies thumbnails, so they need none.
+func sanitizeArgs(opts CompressionOptions) []string {
+	var args []string
+	if opts.Sanitize.JavaScript {
+		// The document view includes the open action
+		args = append(args, "-dPreserveDocView=false")
+	}
+	if opts.Sanitize.EmbeddedFiles {
+		args = append(args, "-dPreserveEmbeddedFiles=false")
+	}
+	if opts.Sanitize.Forms == FormsRemove {
+		args = append(args, "-dShowAcroForm=false")
+	}
+	return args
+}
+
+// annotationDefs returns PostScript that limits the annotations the PDF
+// interpreter draws (ShowAnnotTypes) and passes on as annotations
+// (PreserveAnnotTypes), or "" if all are kept. It must run before the
+// input. Flattened fields are drawn but not preserved.
+func annotationDefs(opts CompressionOptions) string {
+	s := opts.Sanitize
+	if !s.Annotations && !s.EmbeddedFiles && s.Forms == FormsKeep {
+		return ""
+	}
+	var show, preserve []string
+	if !s.Annotations {
+		for _, t := range annotationTypes {
+			if t == "FileAttachment" && s.EmbeddedFiles {
+				continue
+			}
+			show = append(show, "/"+t)
+			preserve = append(preserve, "/"+t)
+		}
+	}
+	switch s.Forms {
+	case FormsKeep:
+		show = append(show, "/Widget")
+		preserve = append(preserve, "/Widget")
+	case FormsFlatten:
+		show = append(show, "/Widget")
+	}
+	return fmt.Sprintf("/ShowAnnotTypes [%s] def /PreserveAnnotTypes [%s] def",
+		strings.Join(show, " "), strings.Join(preserve, " "))
+}
+
+// ActiveContent counts the parts of a PDF that Sanitize can remove
+type ActiveContent struct {
+	JavaScript    int // JavaScript actions
+	OpenActions   int // actions run when the document opens
+	EmbeddedFiles int
+	FormFields    int
+	Annotations   int // annotations other than form fields
+	Thumbnails    int
+}
+
+// InspectContent counts the active and hidden content of the PDF at path.
+// It reads every object with qpdf if it is installed; otherwise only
+// objects outside compressed object streams are seen.
+func InspectContent(ctx context.Context, path string) (ActiveContent, error) {
+	if _, err := exec.LookPath(GetQPDFCommand()); err == nil {
+		if content, err := inspectQPDF(ctx, path); err == nil {
+			return content, nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ActiveContent{}, fmt.Errorf("failed to inspect file: %w", err)
+	}
+	return inspectRaw(data), nil
+}
+
+// inspectQPDF walks the objects in qpdf's JSON dump. qpdf 11 and later
+// nest them under "qpdf" (JSON version 2), earlier versions under
+// "objects"; both write names as "/Name".
+func inspectQPDF(ctx context.Context, path string) (ActiveContent, error) {
+	var out []byte
+	var err error
+	for _, args := range [][]string{
+		{"--json=2", "--json-key=qpdf", path},
+		{"--json", "--json-key=objects", path},
+	} {
+		out, err = exec.CommandContext(ctx, GetQPDFCommand(), args...).Output()
+		if err == nil || isExitCode(err, qpdfExitWarnings) {
+			err = nil
+			break
+		}
+	}
+	if err != nil {
+		return ActiveContent{}, fmt.Errorf("failed to inspect file: %w", err)
+	}
+	var doc any
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return ActiveContent{}, fmt.Errorf("failed to parse qpdf output: %w", err)
+	}
+
+	var c ActiveContent
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]any:
+			c.count(v)
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(doc)
+	return c, nil
+}
+
+// count adds what the PDF dictionary d is to c
+func (c *ActiveContent) count(d map[string]any) {
+	name := func(key string) string {
+		s, _ := d[key].(string)
+		return s
+	}
+	if name("/S") == "/JavaScript" {
+		c.JavaScript++
+	}
+	if name("/Type") == "/Catalog" {
+		if _, ok := d["/OpenAction"]; ok {
+			c.OpenActions++
+		}
+		if _, ok := d["/AA"]; ok {
+			c.OpenActions++
+		}
+	}
+	if _, ok := d["/EF"]; ok {
+		c.EmbeddedFiles++
+	}
+	if _, ok := d["/FT"]; ok {
+		c.FormFields++
+	}
+	if _, ok := d["/Rect"]; ok {
+		if subtype := name("/Subtype"); subtype != "" && subtype != "/Widget" {
+			c.Annotations++
+		}
+	}
+	if _, ok := d["/Thumb"]; ok {
+		c.Thumbnails++
+	}
+}
+
+var (
+	rawJavaScript = regexp.MustCompile(`/S\s*/JavaScript\b`)
+	rawOpenAction = regexp.MustCompile(`/OpenAction\b`)
+	rawEmbedded   = regexp.MustCompile(`/EF\s*<<`)
+	rawField      = regexp.MustCompile(`/FT\s*/`)
+	rawAnnotation = regexp.MustCompile(`/Subtype\s*/(` + strings.Join(annotationTypes, "|") + `)\b`)
+	rawThumbnail  = regexp.MustCompile(`/Thumb\b`)
+)
+
+// inspectRaw counts by searching the file's bytes, which misses objects
+// in compressed object streams
+func inspectRaw(data []byte) ActiveContent {
+	n := func(re *regexp.Regexp) int {
+		return len(re.FindAllIndex(data, -1))
+	}
+	return ActiveContent{
+		JavaScript:    n(rawJavaScript),
+		OpenActions:   n(rawOpenAction),
+		EmbeddedFiles: n(rawEmbedded),
+		FormFields:    n(rawField),
+		Annotations:   n(rawAnnotation),
+		Thumbnails:    n(rawThumbnail),
+	}
+}
+
+// RemovedContent returns what the PDF at inputPath has that outputPath,
+// compressed from it with s, no longer has, and what of the content s
+// removes outputPath still has, such as scripts of kept links
+func RemovedContent(ctx context.Context, inputPath, outputPath string, s Sanitize) (removed, left ActiveContent, err error) {
+	before, err := InspectContent(ctx, inputPath)
+	if err != nil {
+		return ActiveContent{}, ActiveContent{}, err
+	}
+	after, err := InspectContent(ctx, outputPath)
+	if err != nil {
+		return ActiveContent{}, ActiveContent{}, err
+	}
+	return before.Removed(after), s.Targets(after), nil
+}
+
+// Removed returns what c has that after does not
+func (c ActiveContent) Removed(after ActiveContent) ActiveContent {
+	sub := func(a, b int) int {
+		return max(a-b, 0)
+	}
+	return ActiveContent{
+		JavaScript:    sub(c.JavaScript, after.JavaScript),
+		OpenActions:   sub(c.OpenActions, after.OpenActions),
+		EmbeddedFiles: sub(c.EmbeddedFiles, after.EmbeddedFiles),
+		FormFields:    sub(c.FormFields, after.FormFields),
+		Annotations:   sub(c.Annotations, after.Annotations),
+		Thumbnails:    sub(c.Thumbnails, after.Thumbnails),
+	}
+}
+
+// IsZero reports whether c counts nothing
+func (c ActiveContent) IsZero() bool {
+	return c == ActiveContent{}
+}
+
+// Add returns the sum of c and d
+func (c ActiveContent) Add(d ActiveContent) ActiveContent {
+	return ActiveContent{
+		JavaScript:    c.JavaScript + d.JavaScript,
+		OpenActions:   c.OpenActions + d.OpenActions,
+		EmbeddedFiles: c.EmbeddedFiles + d.EmbeddedFiles,
+		FormFields:    c.FormFields + d.FormFields,
+		Annotations:   c.Annotations + d.Annotations,
+		Thumbnails:    c.Thumbnails + d.Thumbnails,
+	}
+}
+
+// String lists the non-zero counts, e.g. "2 scripts, 1 attachment"
+func (c ActiveContent) String() string {
+	var parts []string
+	add := func(n int, one, many string) {
+		switch {
+		case n == 1:
+			parts = append(parts, "1 "+one)
+		case n > 1:
+			parts = append(parts, fmt.Sprintf("%d %s", n, many))
+		}
+	}
+	add(c.JavaScript, "script", "scripts")
+	add(c.OpenActions, "open action", "open actions")
+	add(c.EmbeddedFiles, "attachment", "attachments")
+	add(c.FormFields, "form field", "form fields")
+	add(c.Annotations, "annotation", "annotations")
+	add(c.Thumbnails, "thumbnail", "thumbnails")
+	if len(parts) == 0 {
+		return "nothing"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,321 @@
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxSessionDirs bounds the directories one interpreter may access;
+// beyond it the interpreter is restarted with only the ones in use
+const maxSessionDirs = 64
+
+// Session keeps one Ghostscript interpreter running and compresses files
+// through it one after another, which saves the process start-up cost on
+// batches of small files.
+//
+// Files are fed as PostScript over stdin. SAFER stays on: the interpreter
+// may only read the directories of the files it was given and write to
+// their output directories, and is restarted when a file needs another
+// directory or different settings. A Session is not safe for concurrent use.
+type Session struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	lines   chan string // stdout, closed when Ghostscript exits
+	quit    chan struct{}
+	stderr  *outputBuffer
+	opts    CompressionOptions // settings the interpreter was started with
+	dirs    map[string]bool    // readable directories -> whether also writable
+	scratch string             // private directory for the idle output file
+	seq     int
+}
+
+// NewSession returns a session; the interpreter starts with the first file
+func NewSession() *Session {
+	return &Session{}
+}
+
+// Compress is like CompressPDFWithHooks but runs the file in the
+// session's interpreter. OnStart is called with the interpreter for every
+// file; OnPage is not supported. Options the interpreter cannot handle
+// (another engine, a repair pass) fall back to a separate process.
+func (s *Session) Compress(ctx context.Context, inputPath, outputPath string, opts CompressionOptions, hooks Hooks) (int64, int64, error) {
+	if (opts.Engine != "" && opts.Engine != EngineGhostscript) || opts.Repair {
+		return CompressPDFWithHooks(ctx, inputPath, outputPath, opts, hooks)
+	}
+
+	// 1. Get initial file size
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to stat input file: %w", err)
+	}
+	initialSize := info.Size()
+	inputInfo := info
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	// 2. Ensure output directory exists
+	inputPath, err = filepath.Abs(inputPath)
+	if err != nil {
+		return initialSize, 0, fmt.Errorf("failed to resolve input path: %w", err)
+	}
+	outputPath, err = filepath.Abs(outputPath)
+	if err != nil {
+		return initialSize, 0, fmt.Errorf("failed to resolve output path: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return initialSize, 0, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// 3. Start or reuse the interpreter
+	if err := s.ensure(opts, filepath.Dir(inputPath), filepath.Dir(outputPath)); err != nil {
+		return initialSize, 0, err
+	}
+	if hooks.OnStart != nil {
+		hooks.OnStart(s.cmd.Process)
+	}
+
+	// 4. Run the file
+	marks, err := docinfoMarks(inputPath, opts)
+	if err != nil {
+		return initialSize, 0, err
+	}
+	if err := s.run(ctx, inputPath, outputPath, opts, marks); err != nil {
+		return initialSize, 0, err
+	}
+
+	// 5. Get final file size
+	info, err = os.Stat(outputPath)
+	if err != nil {
+		return initialSize, 0, fmt.Errorf("failed to stat output file: %w", err)
+	}
+	if err := PreserveAttributes(inputPath, outputPath, inputInfo, opts); err != nil {
+		return initialSize, info.Size(), err
+	}
+	return initialSize, info.Size(), nil
+}
+
+// Close stops the interpreter
+func (s *Session) Close() {
+	s.stop()
+}
+
+// ensure makes sure an interpreter with opts may read inputDir and write
+// to outputDir
+func (s *Session) ensure(opts CompressionOptions, inputDir, outputDir string) error {
+	key := opts
+	key.Timeout = 0 // applied per file
+	key.PreserveTimes, key.PreserveMode, key.PreserveXattrs = false, false, false
+	key.PreserveDates = false
+	key.Title, key.Author, key.Subject, key.Keywords = "", "", "", ""
+	key.SourceHash = ""
+
+	if s.cmd != nil && s.opts == key {
+		_, readable := s.dirs[inputDir]
+		if readable && s.dirs[outputDir] {
+			return nil
+		}
+	}
+
+	// Keep the directories of the previous interpreter unless there are too many
+	allowed := make(map[string]bool)
+	if s.cmd != nil && s.opts == key && len(s.dirs) < maxSessionDirs {
+		for dir, write := range s.dirs {
+			allowed[dir] = write
+		}
+	}
+	if _, ok := allowed[inputDir]; !ok {
+		allowed[inputDir] = false
+	}
+	allowed[outputDir] = true
+	s.stop()
+	return s.start(key, allowed)
+}
+
+func (s *Session) start(opts CompressionOptions, dirs map[string]bool) error {
+	scratch, err := os.MkdirTemp("", "spc-gs-")
+	if err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+
+	level := opts.CompatibilityLevel
+	if level == "" {
+		level = DefaultCompatibilityLevel
+	}
+	sep := string(filepath.Separator)
+	args := []string{
+		"-sDEVICE=pdfwrite",
+		fmt.Sprintf("-dCompatibilityLevel=%s", level),
+		"-dNOPAUSE",
+		"-dQUIET",
+		fmt.Sprintf("-sOutputFile=%s", filepath.Join(scratch, "idle.pdf")),
+		"--permit-file-write=" + scratch + sep,
+	}
+	args = append(args, settingsArgs(opts)...)
+	for dir, write := range dirs {
+		args = append(args, "--permit-file-read="+dir+sep)
+		if write {
+			args = append(args, "--permit-file-write="+dir+sep)
+		}
+	}
+	args = append(args, "-") // read the program from stdin
+
+	cmd := exec.Command(GetGhostscriptCommand(), args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		os.RemoveAll(scratch)
+		return fmt.Errorf("failed to start gs: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		os.RemoveAll(scratch)
+		return fmt.Errorf("failed to start gs: %w", err)
+	}
+	stderr := &outputBuffer{}
+	cmd.Stderr = stderr
+
+	prepareLimits(cmd, opts)
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(scratch)
+		return fmt.Errorf("failed to start gs: %w", err)
+	}
+	applyLimits(cmd.Process, opts)
+
+	lines := make(chan string)
+	quit := make(chan struct{})
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	s.cmd = cmd
+	s.stdin = stdin
+	s.lines = lines
+	s.quit = quit
+	s.stderr = stderr
+	s.opts = opts
+	s.dirs = dirs
+	s.scratch = scratch
+	return nil
+}
+
+// run feeds one file and the pdfmarks in marks to the interpreter and
+// waits until the output is written. Changing OutputFile makes pdfwrite
+// finish the previous file, so the output is switched back to the idle
+// file once the input has run.
+func (s *Session) run(ctx context.Context, inputPath, outputPath string, opts CompressionOptions, marks string) error {
+	s.seq++
+	done := fmt.Sprintf("spc:done:%d", s.seq)
+	script := fmt.Sprintf(
+		"<< /OutputFile (%s) >> setpagedevice\n"+
+			"%s\n"+
+			"{ (%s) run } stopped { (spc:error: ) print $error /errorname get == flush } if\n"+
+			"%s\n"+
+			"<< /OutputFile (%s) >> setpagedevice\n"+
+			"(%s) = flush\n",
+		escapePSString(outputPath), annotationDefs(opts), escapePSString(inputPath), marks,
+		escapePSString(filepath.Join(s.scratch, "idle.pdf")), done)
+
+	s.stderr.take() // drop messages from earlier files
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		waitErr := s.stop()
+		return fmt.Errorf("gs failed: %w, exit: %v", err, waitErr)
+	}
+
+	var output []string
+	failure := ""
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok {
+				// The interpreter died on this file
+				text := s.output(output)
+				err := s.stop()
+				if limitErr := limitError(ctx, opts, err, text); limitErr != nil {
+					return fmt.Errorf("gs: %w", limitErr)
+				}
+				if err == nil {
+					err = errors.New("interpreter exited")
+				}
+				return fmt.Errorf("gs failed: %w, output: %s", err, text)
+			}
+			switch {
+			case line == done:
+				if failure != "" {
+					return fmt.Errorf("gs failed: %s, output: %s", failure, s.output(output))
+				}
+				return nil
+			case strings.HasPrefix(line, "spc:error: "):
+				failure = strings.TrimPrefix(line, "spc:error: ")
+			default:
+				output = append(output, line)
+			}
+		case <-ctx.Done():
+			text := s.output(output)
+			err := s.stop()
+			if limitErr := limitError(ctx, opts, err, text); limitErr != nil {
+				return fmt.Errorf("gs: %w", limitErr)
+			}
+			return fmt.Errorf("compression cancelled: %w", ctx.Err())
+		}
+	}
+}
+
+// output joins stdout lines of the current file with what was printed on stderr
+func (s *Session) output(lines []string) string {
+	return strings.TrimSpace(strings.Join(lines, "\n") + "\n" + s.stderr.take())
+}
+
+// stop kills the interpreter and returns its exit error
+func (s *Session) stop() error {
+	if s.cmd == nil {
+		return nil
+	}
+	s.stdin.Close()
+	s.cmd.Process.Kill()
+	close(s.quit)
+	err := s.cmd.Wait()
+	os.RemoveAll(s.scratch)
+	s.cmd = nil
+	return err
+}
+
+// outputBuffer collects a process's stderr while it is running
+type outputBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// take returns the collected output and clears the buffer
+func (b *outputBuffer) take() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.buf.String()
+	b.buf.Reset()
+	return s
+}
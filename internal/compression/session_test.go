@@ -0,0 +1,43 @@
+package compression
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/testutil"
+)
+
+// BenchmarkCompress compares starting Ghostscript per file with feeding
+// the files to one Session, on small one-page PDFs:
+//
+//	go test ./internal/compression -run '^$' -bench Compress
+func BenchmarkCompress(b *testing.B) {
+	if _, err := exec.LookPath(GetGhostscriptCommand()); err != nil {
+		b.Skip("Ghostscript is not installed")
+	}
+	input := filepath.Join(b.TempDir(), "in.pdf")
+	testutil.WritePDF(b, input, "Benchmark page")
+	opts := CompressionOptions{Quality: "ebook"}
+
+	b.Run("ProcessPerFile", func(b *testing.B) {
+		output := filepath.Join(b.TempDir(), "out.pdf")
+		for b.Loop() {
+			if _, _, err := CompressPDF(input, output, opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Session", func(b *testing.B) {
+		output := filepath.Join(b.TempDir(), "out.pdf")
+		session := NewSession()
+		defer session.Close()
+		for b.Loop() {
+			if _, _, err := session.Compress(context.Background(), input, output, opts, Hooks{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
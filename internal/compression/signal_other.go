@@ -0,0 +1,25 @@
+//go:build !unix
+
+package compression
+
+import (
+	"errors"
+	"os"
+)
+
+var errSuspendUnsupported = errors.New("suspending processes is not supported on this platform")
+
+// SuspendProcess is not supported on this platform
+func SuspendProcess(p *os.Process) error {
+	return errSuspendUnsupported
+}
+
+// ResumeProcess is not supported on this platform
+func ResumeProcess(p *os.Process) error {
+	return errSuspendUnsupported
+}
+
+// CanSuspend reports whether running processes can be suspended on this OS
+func CanSuspend() bool {
+	return false
+}
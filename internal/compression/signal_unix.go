@@ -0,0 +1,23 @@
+//go:build unix
+
+package compression
+
+import (
+	"os"
+	"syscall"
+)
+
+// SuspendProcess stops a running Ghostscript process (SIGSTOP)
+func SuspendProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGSTOP)
+}
+
+// ResumeProcess continues a process stopped by SuspendProcess (SIGCONT)
+func ResumeProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGCONT)
+}
+
+// CanSuspend reports whether running processes can be suspended on this OS
+func CanSuspend() bool {
+	return true
+}
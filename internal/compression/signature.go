@@ -0,0 +1,93 @@
+package compression
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+)
+
+// ErrSigned is returned for digitally signed inputs that are not
+// compressed, as compression would invalidate their signatures
+var ErrSigned = errors.New("file is digitally signed")
+
+// byteRange marks a signature dictionary: the byte ranges of the file
+// covered by the signature
+var byteRange = regexp.MustCompile(`/ByteRange\s*\[`)
+
+// signatureChunk is how much of the file IsSigned reads at a time
+const signatureChunk = 1 << 20
+
+// IsSigned reports whether the PDF at path carries a digital signature.
+// The signed byte ranges refer to the file itself, so signature
+// dictionaries are never compressed and the bytes can be searched directly.
+func IsSigned(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	var scanner signatureScanner
+	buf := make([]byte, signatureChunk)
+	for !scanner.signed {
+		n, err := f.Read(buf)
+		scanner.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return scanner.signed, nil
+}
+
+// Digest is what one read of an input tells about it
+type Digest struct {
+	Hash   string // SHA-256, hex encoded
+	Signed bool   // see IsSigned
+}
+
+// DigestFile reads the file at path once for its SHA-256 and whether it
+// is signed, for callers that need both
+func DigestFile(path string) (Digest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Digest{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	var scanner signatureScanner
+	if _, err := io.Copy(io.MultiWriter(h, &scanner), f); err != nil {
+		return Digest{}, err
+	}
+	return Digest{Hash: hex.EncodeToString(h.Sum(nil)), Signed: scanner.signed}, nil
+}
+
+// signatureScanner searches the data written to it for a signature
+type signatureScanner struct {
+	tail   []byte // end of the previous write, so a key split between two is found
+	signed bool
+}
+
+func (s *signatureScanner) Write(p []byte) (int, error) {
+	if s.signed {
+		return len(p), nil
+	}
+	const overlap = 64
+	joint := append(s.tail, p[:min(len(p), overlap)]...)
+	if byteRange.Match(joint) || byteRange.Match(p) {
+		s.signed = true
+		return len(p), nil
+	}
+	if len(p) >= overlap {
+		s.tail = append(s.tail[:0], p[len(p)-overlap:]...)
+	} else {
+		s.tail = append(s.tail[:0], joint[max(len(joint)-overlap, 0):]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,67 @@
+package compression
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDigestFile(t *testing.T) {
+	signature := "<< /Type /Sig /ByteRange [0 1 2 3] >>"
+	// Puts the signature key across the boundary of IsSigned's chunks
+	straddling := strings.Repeat(" ", signatureChunk-5) + signature
+	tests := []struct {
+		name   string
+		data   string
+		signed bool
+	}{
+		{"unsigned", "%PDF-1.4\n%%EOF\n", false},
+		{"signed", "%PDF-1.7\n" + signature + "\n%%EOF\n", true},
+		{"key split between chunks", straddling, true},
+		{"signed at the end", strings.Repeat(" ", 3*signatureChunk) + signature, true},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "in.pdf")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			sum := sha256.Sum256([]byte(tt.data))
+
+			digest, err := DigestFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if digest.Signed != tt.signed || digest.Hash != hex.EncodeToString(sum[:]) {
+				t.Errorf("DigestFile = %+v, want signed %v and the file's SHA-256", digest, tt.signed)
+			}
+			if signed, err := IsSigned(path); err != nil || signed != tt.signed {
+				t.Errorf("IsSigned = %v, %v, want %v", signed, err, tt.signed)
+			}
+		})
+	}
+}
+
+func TestSignatureScannerSmallWrites(t *testing.T) {
+	var s signatureScanner
+	for _, b := range []byte("xx /ByteRange [0 1 2 3]") {
+		s.Write([]byte{b})
+	}
+	if !s.signed {
+		t.Error("signature written a byte at a time was not found")
+	}
+}
+
+func TestNewStampUsesSourceHash(t *testing.T) {
+	stamp, err := NewStamp(filepath.Join(t.TempDir(), "missing.pdf"), CompressionOptions{Quality: "ebook", SourceHash: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stamp.SourceHash != "abc" || stamp.Preset != "ebook" {
+		t.Errorf("NewStamp = %+v, want the given hash and preset", stamp)
+	}
+}
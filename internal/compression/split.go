@@ -0,0 +1,229 @@
+package compression
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// PageRange is an inclusive, 1-based range of pages
+type PageRange struct {
+	First int
+	Last  int
+}
+
+// Bookmark is an outline entry pointing at a page
+type Bookmark struct {
+	Title string
+	Page  int // 1-based, 0 if the destination is unknown
+	Open  bool
+	Kids  []Bookmark
+}
+
+// PageCount returns the number of pages in a PDF
+func PageCount(ctx context.Context, path string) (int, error) {
+	// qpdf is fast and exact; fall back to Ghostscript
+	if _, err := exec.LookPath(GetQPDFCommand()); err == nil {
+		out, err := exec.CommandContext(ctx, GetQPDFCommand(), "--show-npages", path).Output()
+		if n, convErr := strconv.Atoi(strings.TrimSpace(string(out))); err == nil && convErr == nil {
+			return n, nil
+		}
+	}
+
+	// Only the input file needs to be readable, so SAFER stays on
+	cmd := exec.CommandContext(ctx, GetGhostscriptCommand(),
+		"-q", "-dNODISPLAY", "-dNOPAUSE", "-dBATCH",
+		"--permit-file-read="+path,
+		"-c", fmt.Sprintf("(%s) (r) file runpdfbegin pdfpagecount = quit", escapePSString(path)),
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pages: %w, output: %s", err, string(out))
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("failed to count pages: no output")
+	}
+	n, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pages: unexpected output %q", string(out))
+	}
+	return n, nil
+}
+
+// SplitPages divides pages into at most chunks ranges of at least
+// minPages pages each (the last range may be shorter only if pages is).
+func SplitPages(pages, chunks, minPages int) []PageRange {
+	if minPages < 1 {
+		minPages = 1
+	}
+	if max := pages / minPages; chunks > max {
+		chunks = max
+	}
+	if chunks < 1 {
+		chunks = 1
+	}
+
+	ranges := make([]PageRange, 0, chunks)
+	first := 1
+	for i := 0; i < chunks; i++ {
+		// Spread the remainder over the first ranges
+		size := pages / chunks
+		if i < pages%chunks {
+			size++
+		}
+		ranges = append(ranges, PageRange{First: first, Last: first + size - 1})
+		first += size
+	}
+	return ranges
+}
+
+// ReadOutline returns the bookmarks of a PDF. It needs qpdf; without it
+// an empty outline is returned.
+func ReadOutline(ctx context.Context, path string) ([]Bookmark, error) {
+	if _, err := exec.LookPath(GetQPDFCommand()); err != nil {
+		return nil, nil
+	}
+
+	out, err := exec.CommandContext(ctx, GetQPDFCommand(), "--json", "--json-key=outlines", path).Output()
+	if err != nil && !isExitCode(err, qpdfExitWarnings) {
+		return nil, fmt.Errorf("failed to read outline: %w", err)
+	}
+
+	type qpdfOutline struct {
+		Title string        `json:"title"`
+		Page  int           `json:"destpageposfrom1"`
+		Open  bool          `json:"open"`
+		Kids  []qpdfOutline `json:"kids"`
+	}
+	var doc struct {
+		Outlines []qpdfOutline `json:"outlines"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse outline: %w", err)
+	}
+
+	var convert func([]qpdfOutline) []Bookmark
+	convert = func(items []qpdfOutline) []Bookmark {
+		var marks []Bookmark
+		for _, o := range items {
+			marks = append(marks, Bookmark{Title: o.Title, Page: o.Page, Open: o.Open, Kids: convert(o.Kids)})
+		}
+		return marks
+	}
+	return convert(doc.Outlines), nil
+}
+
+// MergePDFs concatenates parts into outputPath with Ghostscript,
+// recreates outline as bookmarks and writes the stamp and dates of
+// source that opts ask for. Images in the parts were compressed already,
+// so mergeImageArgs keeps them from being downsampled or re-encoded
+// a second time.
+func MergePDFs(ctx context.Context, parts []string, outputPath string, outline []Bookmark, source string, opts CompressionOptions) error {
+	level := opts.CompatibilityLevel
+	if level == "" {
+		level = DefaultCompatibilityLevel
+	}
+	args := []string{
+		"-sDEVICE=pdfwrite",
+		fmt.Sprintf("-dCompatibilityLevel=%s", level),
+		"-dNOPAUSE",
+		"-dBATCH",
+		"-dQUIET",
+		"-dAutoRotatePages=/None",
+		fmt.Sprintf("-sOutputFile=%s", outputPath),
+	}
+	if opts.MaxMemory > 0 {
+		args = append(args, memoryArgs(opts.MaxMemory)...)
+	}
+	args = append(args, mergeImageArgs...)
+	args = append(args, metadataArgs(opts)...)
+	args = append(args, parts...)
+
+	docinfo, err := docinfoMarks(source, opts)
+	if err != nil {
+		return err
+	}
+	if len(outline) > 0 || docinfo != "" {
+		marks, err := os.CreateTemp("", "spc-outline-*.ps")
+		if err != nil {
+			return fmt.Errorf("failed to write bookmarks: %w", err)
+		}
+		defer os.Remove(marks.Name())
+		marks.WriteString(outlinePdfmarks(outline))
+		if docinfo != "" {
+			marks.WriteString(docinfo + "\n")
+		}
+		marks.Close()
+		args = append(args, marks.Name())
+	}
+
+	cmd := exec.CommandContext(ctx, GetGhostscriptCommand(), args...)
+	if err := runCommand(ctx, cmd, opts, Hooks{}); err != nil {
+		return fmt.Errorf("failed to merge parts: %w", err)
+	}
+	return nil
+}
+
+// mergeImageArgs turn off pdfwrite's default image processing: JPEG and
+// JPEG 2000 data is copied as is and other images stay lossless.
+var mergeImageArgs = []string{
+	"-dDownsampleColorImages=false",
+	"-dDownsampleGrayImages=false",
+	"-dDownsampleMonoImages=false",
+	"-dPassThroughJPEGImages=true",
+	"-dPassThroughJPXImages=true",
+	"-dAutoFilterColorImages=false",
+	"-dAutoFilterGrayImages=false",
+	"-sColorImageFilter=FlateEncode",
+	"-sGrayImageFilter=FlateEncode",
+}
+
+// outlinePdfmarks renders bookmarks as /OUT pdfmarks. Each parent is
+// followed by its kids, with /Count giving the number of direct kids
+// (negative when the entry starts closed).
+func outlinePdfmarks(marks []Bookmark) string {
+	var b strings.Builder
+	var write func([]Bookmark)
+	write = func(items []Bookmark) {
+		for _, m := range items {
+			b.WriteString("[/Title ")
+			b.WriteString(pdfTextString(m.Title))
+			if m.Page > 0 {
+				fmt.Fprintf(&b, " /Page %d /View [/XYZ null null null]", m.Page)
+			}
+			if n := len(m.Kids); n > 0 {
+				if !m.Open {
+					n = -n
+				}
+				fmt.Fprintf(&b, " /Count %d", n)
+			}
+			b.WriteString(" /OUT pdfmark\n")
+			write(m.Kids)
+		}
+	}
+	write(marks)
+	return b.String()
+}
+
+// pdfTextString encodes s as a UTF-16BE hex string so any title survives
+func pdfTextString(s string) string {
+	var b bytes.Buffer
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+func escapePSString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+	return r.Replace(s)
+}
@@ -0,0 +1,127 @@
+package compression
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Provenance written into the document Info of stamped outputs. pdfwrite
+// copies Producer into the XMP metadata packet, but the custom /SPC keys
+// only exist in the Info dictionary, which is where ReadStamp looks.
+const (
+	StampProducer  = "SimplePDFCompress"
+	stampPresetKey = "/SPCPreset"
+	stampSourceKey = "/SPCSourceSHA256"
+)
+
+// stampScanLimit is how much of a large file's end ReadStamp searches;
+// pdfwrite writes the Info dictionary after the pages
+const stampScanLimit = 256 << 10
+
+// Stamp records that a PDF is the output of this app
+type Stamp struct {
+	Preset     string // quality preset used
+	SourceHash string // SHA-256 of the original input
+}
+
+// NewStamp describes the output of compressing inputPath with opts
+func NewStamp(inputPath string, opts CompressionOptions) (Stamp, error) {
+	hash := opts.SourceHash
+	if hash == "" {
+		f, err := os.Open(inputPath)
+		if err != nil {
+			return Stamp{}, fmt.Errorf("failed to hash input file: %w", err)
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return Stamp{}, fmt.Errorf("failed to hash input file: %w", err)
+		}
+		hash = hex.EncodeToString(h.Sum(nil))
+	}
+
+	preset := opts.Quality
+	if preset == "" {
+		preset = "default"
+	}
+	if opts.Engine == EngineQPDF {
+		preset = "lossless"
+	}
+	return Stamp{Preset: preset, SourceHash: hash}, nil
+}
+
+// pdfmark returns the PostScript that writes the stamp into the Info dictionary
+func (s Stamp) pdfmark() string {
+	return fmt.Sprintf("[ /Producer (%s) %s (%s) %s (%s) /DOCINFO pdfmark",
+		StampProducer, stampPresetKey, escapePSString(s.Preset), stampSourceKey, s.SourceHash)
+}
+
+// ReadStamp returns the stamp of a PDF written by this app. It reports
+// false for files without one.
+func ReadStamp(path string) (Stamp, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Stamp{}, false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return Stamp{}, false, err
+	}
+	offset := info.Size() - stampScanLimit
+	if offset < 0 {
+		offset = 0
+	}
+	buf := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return Stamp{}, false, err
+	}
+
+	source, ok := infoString(buf, stampSourceKey)
+	if !ok {
+		return Stamp{}, false, nil
+	}
+	preset, _ := infoString(buf, stampPresetKey)
+	return Stamp{Preset: preset, SourceHash: source}, true, nil
+}
+
+// infoString finds key in data and decodes the literal or hex string
+// that follows it. Only the ASCII values written by Stamp are handled.
+func infoString(data []byte, key string) (string, bool) {
+	i := bytes.LastIndex(data, []byte(key))
+	if i < 0 {
+		return "", false
+	}
+	rest := bytes.TrimLeft(data[i+len(key):], " \t\r\n")
+	if len(rest) == 0 {
+		return "", false
+	}
+	switch rest[0] {
+	case '(':
+		end := bytes.IndexByte(rest, ')')
+		if end < 0 {
+			return "", false
+		}
+		return string(rest[1:end]), true
+	case '<':
+		end := bytes.IndexByte(rest, '>')
+		if end < 0 {
+			return "", false
+		}
+		decoded, err := hex.DecodeString(string(bytes.Join(bytes.Fields(rest[1:end]), nil)))
+		if err != nil {
+			return "", false
+		}
+		// Strip a UTF-16BE encoding of ASCII text
+		if bytes.HasPrefix(decoded, []byte{0xFE, 0xFF}) {
+			decoded = bytes.ReplaceAll(decoded[2:], []byte{0}, nil)
+		}
+		return string(decoded), true
+	}
+	return "", false
+}
@@ -0,0 +1,85 @@
+package compression
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CompressStream compresses the PDF read from r and writes the result to
+// w, returning the input and output sizes like CompressPDF. Ghostscript
+// and qpdf need to seek in their files, so the data passes through a
+// private temporary directory that is removed before returning.
+func CompressStream(ctx context.Context, r io.Reader, w io.Writer, opts CompressionOptions) (int64, int64, error) {
+	return CompressStreamCheck(ctx, r, w, opts, nil)
+}
+
+// CompressStreamCheck is like CompressStream but first calls check, if
+// not nil, with the path of the spooled input. An error from check is
+// returned without compressing or writing anything to w.
+func CompressStreamCheck(ctx context.Context, r io.Reader, w io.Writer, opts CompressionOptions, check func(inputPath string) error) (int64, int64, error) {
+	dir, err := os.MkdirTemp("", "spc-stream-")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 1. Spool the input
+	inputPath := filepath.Join(dir, "input.pdf")
+	outputPath := filepath.Join(dir, "output.pdf")
+	if err := spool(ctx, r, inputPath); err != nil {
+		return 0, 0, err
+	}
+	if check != nil {
+		if err := check(inputPath); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// 2. Compress
+	initialSize, finalSize, err := CompressPDFContext(ctx, inputPath, outputPath, opts)
+	if err != nil {
+		return initialSize, 0, err
+	}
+
+	// 3. Write the result
+	out, err := os.Open(outputPath)
+	if err != nil {
+		return initialSize, 0, fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(w, out); err != nil {
+		return initialSize, 0, fmt.Errorf("failed to write output: %w", err)
+	}
+	return initialSize, finalSize, nil
+}
+
+func spool(ctx context.Context, r io.Reader, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create input file: %w", err)
+	}
+	if _, err := io.Copy(f, &contextReader{ctx: ctx, r: r}); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write input file: %w", err)
+	}
+	return nil
+}
+
+// contextReader stops reading once ctx is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
@@ -0,0 +1,49 @@
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ValidatePDF performs a cheap sanity check on a PDF file: it must start
+// with the %PDF- header and end with an %%EOF marker. It does not parse
+// the document, but catches missing, empty and truncated outputs.
+func ValidatePDF(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return errors.New("file is empty")
+	}
+
+	// 1. Header
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(f, header); err != nil || string(header) != "%PDF-" {
+		return fmt.Errorf("%s is not a PDF file", path)
+	}
+
+	// 2. Trailer (allow trailing whitespace/garbage after %%EOF)
+	const tailSize = 1024
+	offset := info.Size() - tailSize
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return err
+	}
+	if !bytes.Contains(tail, []byte("%%EOF")) {
+		return fmt.Errorf("%s is truncated (missing %%%%EOF)", path)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build linux || dragonfly || openbsd || solaris
+
+package fileops
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// accessTime returns the last access time of info, or its modification
+// time if the system does not report one
+func accessTime(info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Atim.Unix())
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !dragonfly && !openbsd && !solaris && !darwin && !freebsd && !netbsd && !windows
+
+package fileops
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime returns the modification time, as there is no portable
+// access time on this system
+func accessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,42 @@
+package fileops
+
+import (
+	"fmt"
+	"os"
+)
+
+// Attributes selects what CopyAttributes copies
+type Attributes struct {
+	Times  bool // modification and access time
+	Mode   bool // permission bits
+	Xattrs bool // extended attributes and ACLs (Linux only)
+}
+
+// CopyAttributes copies the attributes of src selected in which to dst.
+// info is src's FileInfo taken before src was read, as reading it may
+// update its access time; nil stats src now. Times are copied last, as
+// the other changes may touch them.
+func CopyAttributes(src, dst string, info os.FileInfo, which Attributes) error {
+	if info == nil {
+		var err error
+		if info, err = os.Stat(src); err != nil {
+			return err
+		}
+	}
+	if which.Xattrs {
+		if err := copyXattrs(src, dst); err != nil {
+			return fmt.Errorf("failed to copy extended attributes: %w", err)
+		}
+	}
+	if which.Mode {
+		if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to copy permissions: %w", err)
+		}
+	}
+	if which.Times {
+		if err := os.Chtimes(dst, accessTime(info), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to copy file times: %w", err)
+		}
+	}
+	return nil
+}
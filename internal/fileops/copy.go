@@ -0,0 +1,37 @@
+package fileops
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CopyFile copies src to dst through a temporary file, so dst is never
+// left half written
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".spc-copy-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	// Temporary files are private; outputs get the usual permissions
+	os.Chmod(tmp.Name(), 0644)
+	return os.Rename(tmp.Name(), dst)
+}
@@ -0,0 +1,35 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNoTrash is returned by Trash when the file cannot be moved to a trash
+// on this system or file system
+var ErrNoTrash = errors.New("trash is not available")
+
+// Trash moves path to the desktop trash, where the user can restore it
+func Trash(path string) error {
+	if _, err := os.Lstat(path); err != nil {
+		return err
+	}
+	return trash(path)
+}
+
+// Remove moves path to the trash, deleting it permanently only if there
+// is no trash for it. It reports whether the file went to the trash.
+func Remove(path string) (bool, error) {
+	err := Trash(path)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, err
+	}
+	if rmErr := os.Remove(path); rmErr != nil {
+		return false, fmt.Errorf("failed to delete %s: %w", path, rmErr)
+	}
+	return false, nil
+}
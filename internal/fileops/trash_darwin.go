@@ -0,0 +1,44 @@
+//go:build darwin
+
+package fileops
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// trash asks Finder to delete the file, which allows "Put Back". Without
+// Finder (e.g. over SSH) the file is moved to ~/.Trash directly.
+func trash(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	script := fmt.Sprintf(`tell application "Finder" to delete POSIX file "%s"`, escapeAppleScript(abs))
+	if err := exec.Command("osascript", "-e", script).Run(); err == nil {
+		return nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoTrash, err)
+	}
+	dir := filepath.Join(home, ".Trash")
+	name := filepath.Base(abs)
+	if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+		ext := filepath.Ext(name)
+		name = fmt.Sprintf("%s %s%s", strings.TrimSuffix(name, ext), time.Now().Format("15.04.05.000"), ext)
+	}
+	if err := os.Rename(abs, filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("%w: %v", ErrNoTrash, err)
+	}
+	return nil
+}
+
+func escapeAppleScript(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
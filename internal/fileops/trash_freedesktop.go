@@ -0,0 +1,161 @@
+//go:build linux || dragonfly || freebsd || netbsd || openbsd
+
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// trash follows the freedesktop.org Trash specification: the file is
+// renamed into Trash/files and described by a .trashinfo file in
+// Trash/info. Files on other file systems than the home trash go to a
+// trash at the top of their own file system.
+func trash(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	dev, err := device(abs)
+	if err != nil {
+		return err
+	}
+
+	// 1. Pick a trash on the same file system, so the move is a rename
+	dir, infoPath := "", abs
+	if home := homeTrash(); home != "" {
+		if err := os.MkdirAll(home, 0700); err == nil {
+			if homeDev, err := device(home); err == nil && homeDev == dev {
+				dir = home
+			}
+		}
+	}
+	if dir == "" {
+		top, err := topDir(abs, dev)
+		if err != nil {
+			return err
+		}
+		if dir, err = topTrash(top); err != nil {
+			return err
+		}
+		// Top directory trashes record paths relative to the top directory
+		if rel, err := filepath.Rel(top, abs); err == nil {
+			infoPath = rel
+		}
+	}
+	filesDir := filepath.Join(dir, "files")
+	infoDir := filepath.Join(dir, "info")
+	if err := os.MkdirAll(filesDir, 0700); err != nil {
+		return fmt.Errorf("%w: %v", ErrNoTrash, err)
+	}
+	if err := os.MkdirAll(infoDir, 0700); err != nil {
+		return fmt.Errorf("%w: %v", ErrNoTrash, err)
+	}
+
+	// 2. Claim a unique name by creating its .trashinfo exclusively
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		escapeTrashPath(infoPath), time.Now().Format("2006-01-02T15:04:05"))
+	base := filepath.Base(abs)
+	ext := filepath.Ext(base)
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = fmt.Sprintf("%s.%d%s", strings.TrimSuffix(base, ext), n, ext)
+		}
+		infoFile := filepath.Join(infoDir, name+".trashinfo")
+		f, err := os.OpenFile(infoFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrNoTrash, err)
+		}
+		_, err = f.WriteString(info)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(abs, filepath.Join(filesDir, name))
+		}
+		if err != nil {
+			os.Remove(infoFile)
+			return fmt.Errorf("%w: %v", ErrNoTrash, err)
+		}
+		return nil
+	}
+}
+
+// homeTrash returns $XDG_DATA_HOME/Trash
+func homeTrash() string {
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		data = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(data, "Trash")
+}
+
+// topDir returns the mount point of the file system holding path
+func topDir(path string, dev uint64) (string, error) {
+	dir := filepath.Dir(path)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil
+		}
+		parentDev, err := device(parent)
+		if err != nil || parentDev != dev {
+			return dir, nil
+		}
+		dir = parent
+	}
+}
+
+// topTrash returns $top/.Trash/$uid if the administrator prepared a
+// shared .Trash (a sticky directory, not a symlink), else $top/.Trash-$uid
+func topTrash(top string) (string, error) {
+	uid := strconv.Itoa(os.Getuid())
+	shared := filepath.Join(top, ".Trash")
+	if fi, err := os.Lstat(shared); err == nil && fi.IsDir() && fi.Mode()&os.ModeSticky != 0 {
+		dir := filepath.Join(shared, uid)
+		if err := os.MkdirAll(dir, 0700); err == nil {
+			return dir, nil
+		}
+	}
+	dir := filepath.Join(top, ".Trash-"+uid)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrNoTrash, err)
+	}
+	if fi, err := os.Lstat(dir); err != nil || !fi.IsDir() {
+		return "", ErrNoTrash
+	}
+	return dir, nil
+}
+
+func device(path string) (uint64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
+
+// escapeTrashPath percent-encodes each element of a path as the spec
+// requires, keeping the separators
+func escapeTrashPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
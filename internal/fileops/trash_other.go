@@ -0,0 +1,8 @@
+//go:build !linux && !windows && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package fileops
+
+// There is no desktop trash on this platform
+func trash(path string) error {
+	return ErrNoTrash
+}
@@ -0,0 +1,65 @@
+//go:build windows
+
+package fileops
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// SHFileOperationW operation and flags
+const (
+	foDelete          = 0x0003
+	fofSilent         = 0x0004
+	fofNoConfirmation = 0x0010
+	fofAllowUndo      = 0x0040
+	fofNoErrorUI      = 0x0400
+)
+
+// shFileOpStruct is SHFILEOPSTRUCTW. The header packs it to 1 byte on
+// 32-bit Windows, which this layout does not match.
+type shFileOpStruct struct {
+	hwnd                  uintptr
+	wFunc                 uint32
+	pFrom                 *uint16
+	pTo                   *uint16
+	fFlags                uint16
+	fAnyOperationsAborted int32
+	hNameMappings         uintptr
+	lpszProgressTitle     *uint16
+}
+
+var procSHFileOperation = syscall.NewLazyDLL("shell32.dll").NewProc("SHFileOperationW")
+
+// trash moves the file to the Recycle Bin
+func trash(path string) error {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		return ErrNoTrash
+	}
+	if err := procSHFileOperation.Find(); err != nil {
+		return fmt.Errorf("%w: %v", ErrNoTrash, err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	// pFrom is a list of names ending in an empty one
+	from, err := syscall.UTF16FromString(abs)
+	if err != nil {
+		return err
+	}
+	from = append(from, 0)
+
+	op := shFileOpStruct{
+		wFunc:  foDelete,
+		pFrom:  &from[0],
+		fFlags: fofAllowUndo | fofNoConfirmation | fofSilent | fofNoErrorUI,
+	}
+	ret, _, _ := procSHFileOperation.Call(uintptr(unsafe.Pointer(&op)))
+	if ret != 0 || op.fAnyOperationsAborted != 0 {
+		return fmt.Errorf("%w: SHFileOperation failed with code %#x", ErrNoTrash, ret)
+	}
+	return nil
+}
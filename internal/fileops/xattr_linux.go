@@ -0,0 +1,86 @@
+package fileops
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+)
+
+// copyXattrs copies the user extended attributes of src and its access
+// ACL (stored as system.posix_acl_access) to dst. Attributes in the
+// security and trusted namespaces need privileges and are left alone.
+// A dst on a file system without extended attributes is not an error.
+func copyXattrs(src, dst string) error {
+	names, err := listXattrs(src)
+	if err != nil {
+		if errors.Is(err, syscall.ENOTSUP) {
+			return nil
+		}
+		return err
+	}
+	for _, name := range names {
+		if !strings.HasPrefix(name, "user.") && name != "system.posix_acl_access" {
+			continue
+		}
+		value, err := getXattr(src, name)
+		if err != nil {
+			return err
+		}
+		if err := syscall.Setxattr(dst, name, value, 0); err != nil {
+			if errors.Is(err, syscall.ENOTSUP) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// listXattrs returns the names of the extended attributes of path
+func listXattrs(path string) ([]string, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	// The list may grow between the two calls
+	for {
+		n, err := syscall.Listxattr(path, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			buf = make([]byte, 2*len(buf))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf = buf[:n]
+		break
+	}
+	var names []string
+	for _, name := range strings.Split(string(buf), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// getXattr returns the value of the extended attribute name of path
+func getXattr(path, name string) ([]byte, error) {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	for {
+		n, err := syscall.Getxattr(path, name, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			buf = make([]byte, 2*len(buf)+1)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package fileops
+
+// copyXattrs does nothing; extended attributes are only copied on Linux
+func copyXattrs(src, dst string) error {
+	return nil
+}
@@ -0,0 +1,283 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+	"github.com/thelaonerd/simplepdfcompress/internal/worker"
+)
+
+// Status of a journaled job
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+	// StatusSkipped is a job that finished without an output to keep: a
+	// signed input that was skipped or an output deleted for saving too
+	// little. Resuming does not run it again.
+	StatusSkipped Status = "skipped"
+)
+
+// Entry is the journaled state of one job
+type Entry struct {
+	Job    worker.Job
+	Status Status
+	Error  string
+	// Output is the file a done job wrote. It differs from
+	// Job.OutputPath for {pages} and {ratio} templates and renamed outputs.
+	Output string
+}
+
+// record is one line of the journal file. The file is append-only JSON
+// lines so that a crash loses at most the line being written:
+//
+//	{"type":"batch","created":...}
+//	{"type":"job","id":0,"input":...,"output":...,"options":{...}}
+//	{"type":"status","id":0,"status":"done","written":...}
+type record struct {
+	Type    string                          `json:"type"`
+	Created time.Time                       `json:"created,omitzero"`
+	ID      int                             `json:"id"`
+	Input   string                          `json:"input,omitempty"`
+	Output  string                          `json:"output,omitempty"`
+	Options *compression.CompressionOptions `json:"options,omitempty"`
+	Status  Status                          `json:"status,omitempty"`
+	Error   string                          `json:"error,omitempty"`
+	Written string                          `json:"written,omitempty"`
+}
+
+// Journal records the progress of a batch on disk so that it can be
+// resumed after a crash or restart.
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	created time.Time
+	entries []Entry
+	ids     map[string]int // output path -> entry index
+}
+
+// Path returns the location of the batch journal in the user config dir
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "simplepdfcompress", "batch-journal.jsonl"), nil
+}
+
+// Create starts a new journal for jobs, replacing any previous one
+func Create(jobs []worker.Job) (*Journal, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create journal: %w", err)
+	}
+
+	j := &Journal{
+		path:    path,
+		file:    file,
+		created: time.Now(),
+		ids:     make(map[string]int),
+	}
+	if err := j.write(record{Type: "batch", Created: j.created}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := j.Add(jobs...); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// Load opens the journal left behind by an unfinished batch.
+// It returns an error satisfying errors.Is(err, os.ErrNotExist) if there is none.
+func Load() (*Journal, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Journal{
+		path: path,
+		file: file,
+		ids:  make(map[string]int),
+	}
+
+	// Replay records; a torn last line from a crash is ignored
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		switch rec.Type {
+		case "batch":
+			j.created = rec.Created
+		case "job":
+			job := worker.Job{InputPath: rec.Input, OutputPath: rec.Output}
+			if rec.Options != nil {
+				job.Options = *rec.Options
+			}
+			if rec.ID != len(j.entries) {
+				continue
+			}
+			j.ids[job.OutputPath] = rec.ID
+			j.entries = append(j.entries, Entry{Job: job, Status: StatusPending})
+		case "status":
+			if rec.ID >= 0 && rec.ID < len(j.entries) {
+				j.entries[rec.ID].Status = rec.Status
+				j.entries[rec.ID].Error = rec.Error
+				j.entries[rec.ID].Output = rec.Written
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	// Terminate a possibly torn last line so new records start cleanly
+	if _, err := file.Write([]byte("\n")); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write journal: %w", err)
+	}
+	return j, nil
+}
+
+// Add records additional jobs, e.g. files added while the batch runs
+func (j *Journal) Add(jobs ...worker.Job) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, job := range jobs {
+		if _, ok := j.ids[job.OutputPath]; ok {
+			continue
+		}
+		id := len(j.entries)
+		opts := job.Options
+		if err := j.write(record{Type: "job", ID: id, Input: job.InputPath, Output: job.OutputPath, Options: &opts}); err != nil {
+			return err
+		}
+		j.ids[job.OutputPath] = id
+		j.entries = append(j.entries, Entry{Job: job, Status: StatusPending})
+	}
+	return nil
+}
+
+// Record stores the outcome of a job from its result
+func (j *Journal) Record(res worker.Result) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	id, ok := j.ids[res.Job.OutputPath]
+	if !ok {
+		return fmt.Errorf("job %s is not in the journal", res.Job.InputPath)
+	}
+
+	rec := record{Type: "status", ID: id}
+	switch {
+	case res.Error != nil:
+		rec.Status = StatusFailed
+		rec.Error = res.Error.Error()
+	case res.Signature == worker.SignedSkipped,
+		res.NotShrunk && res.Job.Shrink.Action == worker.ShrinkDelete:
+		rec.Status = StatusSkipped
+	default:
+		rec.Status = StatusDone
+		rec.Written = res.OutputPath
+	}
+	if err := j.write(rec); err != nil {
+		return err
+	}
+	j.entries[id].Status = rec.Status
+	j.entries[id].Error = rec.Error
+	j.entries[id].Output = rec.Written
+	return nil
+}
+
+// Created returns when the batch was started
+func (j *Journal) Created() time.Time {
+	return j.created
+}
+
+// Entries returns a snapshot of all journaled jobs
+func (j *Journal) Entries() []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Entry(nil), j.entries...)
+}
+
+// Remaining returns the jobs that still need to run. Completed jobs are
+// left out only if their output still exists and looks like a valid PDF;
+// skipped jobs are always left out.
+func (j *Journal) Remaining() []worker.Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var jobs []worker.Job
+	for _, e := range j.entries {
+		output := e.Output
+		if output == "" {
+			output = e.Job.OutputPath
+		}
+		switch {
+		case e.Status == StatusSkipped:
+			continue
+		case e.Status == StatusDone && compression.ValidatePDF(output) == nil:
+			continue
+		}
+		jobs = append(jobs, e.Job)
+	}
+	return jobs
+}
+
+// Close closes the journal file, keeping it on disk for a later resume
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// Remove closes and deletes the journal once the batch has finished
+func (j *Journal) Remove() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.file.Close()
+	if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (j *Journal) write(rec record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err := j.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
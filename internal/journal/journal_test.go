@@ -0,0 +1,186 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+	"github.com/thelaonerd/simplepdfcompress/internal/worker"
+)
+
+const validPDF = "%PDF-1.4\n%%EOF\n"
+
+// useConfigDir points the journal at a private config folder
+func useConfigDir(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func jobs(dir string, names ...string) []worker.Job {
+	var out []worker.Job
+	for _, name := range names {
+		out = append(out, worker.Job{
+			InputPath:  filepath.Join(dir, name+".pdf"),
+			OutputPath: filepath.Join(dir, "out", name+".pdf"),
+			Options:    compression.CompressionOptions{Quality: "ebook"},
+		})
+	}
+	return out
+}
+
+func inputs(jobs []worker.Job) []string {
+	var paths []string
+	for _, job := range jobs {
+		paths = append(paths, filepath.Base(job.InputPath))
+	}
+	return paths
+}
+
+func TestLoadWithoutJournal(t *testing.T) {
+	useConfigDir(t)
+	if _, err := Load(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load = %v, want ErrNotExist", err)
+	}
+}
+
+func TestResume(t *testing.T) {
+	useConfigDir(t)
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "out"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	all := jobs(dir, "done", "renamed", "gone", "failed", "signed", "deleted", "pending")
+	done, renamed, gone, failed, signed, deleted := all[0], all[1], all[2], all[3], all[4], all[5]
+
+	j, err := Create(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Jobs already in the journal are not added twice
+	if err := j.Add(all[0], all[6]); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, done.OutputPath, validPDF)
+	renamedOutput := filepath.Join(dir, "out", "renamed (2).pdf")
+	writeFile(t, renamedOutput, validPDF)
+	deleted.Shrink = worker.ShrinkPolicy{MinSavings: 10, Action: worker.ShrinkDelete}
+	for _, res := range []worker.Result{
+		{Job: done, OutputPath: done.OutputPath},
+		{Job: renamed, OutputPath: renamedOutput, Conflict: worker.Renamed},
+		{Job: gone, OutputPath: gone.OutputPath}, // its output is missing
+		{Job: failed, Error: errors.New("gs failed")},
+		{Job: signed, Signature: worker.SignedSkipped},
+		{Job: deleted, OutputPath: deleted.OutputPath, NotShrunk: true},
+	} {
+		if err := j.Record(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Record(worker.Result{Job: jobs(dir, "unknown")[0]}); err == nil {
+		t.Error("Record of a job that is not in the journal succeeded")
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Remove()
+
+	entries := j.Entries()
+	if len(entries) != len(all) {
+		t.Fatalf("loaded %d entries, want %d", len(entries), len(all))
+	}
+	want := map[string]Status{
+		"done.pdf": StatusDone, "renamed.pdf": StatusDone, "gone.pdf": StatusDone,
+		"failed.pdf": StatusFailed, "signed.pdf": StatusSkipped, "deleted.pdf": StatusSkipped,
+		"pending.pdf": StatusPending,
+	}
+	for _, e := range entries {
+		if got := e.Status; got != want[filepath.Base(e.Job.InputPath)] {
+			t.Errorf("%s: status %s, want %s", filepath.Base(e.Job.InputPath), got, want[filepath.Base(e.Job.InputPath)])
+		}
+		if e.Job.Options.Quality != "ebook" {
+			t.Errorf("%s: options were not restored", e.Job.InputPath)
+		}
+	}
+	if entries[1].Output != renamedOutput {
+		t.Errorf("renamed job wrote %q, want %q", entries[1].Output, renamedOutput)
+	}
+	if entries[3].Error != "gs failed" {
+		t.Errorf("failed job error %q, want %q", entries[3].Error, "gs failed")
+	}
+
+	if got, want := inputs(j.Remaining()), []string{"gone.pdf", "failed.pdf", "pending.pdf"}; !slices.Equal(got, want) {
+		t.Errorf("Remaining = %v, want %v", got, want)
+	}
+}
+
+// A crash while writing leaves a torn last line, which is ignored
+func TestLoadTornLine(t *testing.T) {
+	useConfigDir(t)
+	dir := t.TempDir()
+	all := jobs(dir, "a", "b")
+	j, err := Create(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Record(worker.Result{Job: all[0], Error: errors.New("failed")}); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	path, err := Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"type":"status","id":1,"sta`)
+	f.Close()
+
+	j, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := j.Entries()[1].Status; got != StatusPending {
+		t.Errorf("job with a torn status line is %s, want pending", got)
+	}
+	// Records written after the torn line are read back
+	if err := j.Record(worker.Result{Job: all[1], Signature: worker.SignedSkipped}); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	j, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := j.Entries()[1].Status; got != StatusSkipped {
+		t.Errorf("status after the torn line is %s, want skipped", got)
+	}
+	if err := j.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("journal still exists after Remove")
+	}
+}
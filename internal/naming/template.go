@@ -0,0 +1,286 @@
+package naming
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultTemplate names outputs <input name><suffix>.pdf
+const DefaultTemplate = "{name}{suffix}.pdf"
+
+// Placeholders known before compression
+const (
+	Name   = "name"   // input file name without extension
+	Parent = "parent" // name of the input's folder
+	Suffix = "suffix" // filename suffix setting
+	Preset = "preset" // quality preset
+	Date   = "date"   // start date, 2006-01-02
+	Clock  = "time"   // start time, 150405
+	Size   = "size"   // input size, e.g. 2.4MB
+)
+
+// Placeholders known only after compression. They are left in the output
+// path while the file is compressed and filled in by Resolve.
+const (
+	Pages = "pages" // page count
+	Ratio = "ratio" // size reduction in whole percent
+)
+
+var placeholders = map[string]bool{
+	Name: true, Parent: true, Suffix: true, Preset: true,
+	Date: true, Clock: true, Size: true, Pages: true, Ratio: true,
+}
+
+// Vars are the values of the placeholders known before compression
+type Vars struct {
+	Input  string // input path
+	Suffix string
+	Preset string
+	Time   time.Time
+	Size   int64
+}
+
+// Outcome holds the values of Pages and Ratio
+type Outcome struct {
+	Pages        int
+	OriginalSize int64
+	FinalSize    int64
+}
+
+// part is literal text or a placeholder
+type part struct {
+	text        string
+	placeholder bool
+}
+
+// Template describes an output file name relative to the output folder,
+// such as "{parent}/{name}-small.pdf"
+type Template struct {
+	raw   string
+	parts []part
+}
+
+// Parse checks a template for unknown placeholders, unbalanced braces and
+// characters that are not allowed in file names on this OS
+func Parse(s string) (*Template, error) {
+	return parse(s, runtime.GOOS)
+}
+
+func parse(s, goos string) (*Template, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("template is empty")
+	}
+	t := &Template{raw: s}
+
+	// 1. Split into literals and placeholders
+	rest := s
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		closing := strings.IndexByte(rest, '}')
+		if open < 0 {
+			if closing >= 0 {
+				return nil, fmt.Errorf("unmatched } in template")
+			}
+			t.parts = append(t.parts, part{text: rest})
+			break
+		}
+		if closing >= 0 && closing < open {
+			return nil, fmt.Errorf("unmatched } in template")
+		}
+		if open > 0 {
+			t.parts = append(t.parts, part{text: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unmatched { in template")
+		}
+		name := rest[open+1 : open+end]
+		if !placeholders[name] {
+			return nil, fmt.Errorf("unknown placeholder {%s}", name)
+		}
+		t.parts = append(t.parts, part{text: name, placeholder: true})
+		rest = rest[open+end+1:]
+	}
+
+	// 2. Check the folders and file name it produces
+	sample := strings.ReplaceAll(t.expand(func(string) string { return "x" }), `\`, "/")
+	if strings.HasPrefix(sample, "/") || filepath.IsAbs(sample) || filepath.VolumeName(sample) != "" {
+		return nil, fmt.Errorf("template must be relative to the output folder")
+	}
+	for _, seg := range strings.Split(sample, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return nil, fmt.Errorf("template has an empty or relative folder name")
+		}
+		if err := checkSegment(seg, goos); err != nil {
+			return nil, err
+		}
+	}
+	late := false
+	for _, p := range t.parts {
+		if p.placeholder {
+			late = late || p.text == Pages || p.text == Ratio
+			continue
+		}
+		if late && strings.ContainsAny(p.text, `/\`) {
+			return nil, fmt.Errorf("{%s} and {%s} can only be used in the file name", Pages, Ratio)
+		}
+		if err := checkLiteral(p.text, goos); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// checkLiteral rejects characters that are never valid in a file name
+func checkLiteral(s, goos string) error {
+	illegal := ""
+	switch goos {
+	case "windows":
+		illegal = `<>:"|?*`
+	case "darwin":
+		illegal = ":"
+	}
+	for _, r := range s {
+		if r == 0 || (goos == "windows" && unicode.IsControl(r)) {
+			return fmt.Errorf("template contains a control character")
+		}
+		if strings.ContainsRune(illegal, r) {
+			return fmt.Errorf("%q is not allowed in file names on %s", r, goos)
+		}
+	}
+	return nil
+}
+
+// checkSegment rejects folder and file names Windows reserves
+func checkSegment(seg, goos string) error {
+	if goos != "windows" {
+		return nil
+	}
+	if strings.HasSuffix(seg, ".") || strings.HasSuffix(seg, " ") {
+		return fmt.Errorf("names may not end in a dot or space on Windows: %q", seg)
+	}
+	base := strings.ToUpper(strings.TrimSpace(strings.SplitN(seg, ".", 2)[0]))
+	switch base {
+	case "CON", "PRN", "AUX", "NUL":
+		return fmt.Errorf("%q is a reserved name on Windows", seg)
+	}
+	if len(base) == 4 && (strings.HasPrefix(base, "COM") || strings.HasPrefix(base, "LPT")) && base[3] >= '1' && base[3] <= '9' {
+		return fmt.Errorf("%q is a reserved name on Windows", seg)
+	}
+	return nil
+}
+
+// String returns the template as written
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Uses reports whether the template contains a placeholder
+func (t *Template) Uses(placeholder string) bool {
+	for _, p := range t.parts {
+		if p.placeholder && p.text == placeholder {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand fills in the placeholders known before compression and returns
+// the path relative to the output folder. Pages and Ratio stay in place
+// for Resolve. A .pdf extension is added if the template has none.
+func (t *Template) Expand(v Vars) string {
+	name := strings.TrimSuffix(filepath.Base(v.Input), filepath.Ext(v.Input))
+	if v.Time.IsZero() {
+		v.Time = time.Now()
+	}
+	values := map[string]string{
+		Name:   name,
+		Parent: filepath.Base(filepath.Dir(v.Input)),
+		Suffix: v.Suffix,
+		Preset: v.Preset,
+		Date:   v.Time.Format("2006-01-02"),
+		Clock:  v.Time.Format("150405"),
+		Size:   compactSize(v.Size),
+	}
+	rel := t.expand(func(placeholder string) string {
+		if value, ok := values[placeholder]; ok {
+			return sanitize(value)
+		}
+		return "{" + placeholder + "}"
+	})
+	if !strings.EqualFold(filepath.Ext(rel), ".pdf") {
+		rel += ".pdf"
+	}
+	return filepath.FromSlash(rel)
+}
+
+func (t *Template) expand(value func(string) string) string {
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.placeholder {
+			b.WriteString(value(p.text))
+		} else {
+			b.WriteString(p.text)
+		}
+	}
+	return b.String()
+}
+
+// Pending reports whether path still contains placeholders for Resolve
+func Pending(path string) bool {
+	return NeedsPages(path) || strings.Contains(filepath.Base(path), "{"+Ratio+"}")
+}
+
+// NeedsPages reports whether path still contains {pages}
+func NeedsPages(path string) bool {
+	return strings.Contains(filepath.Base(path), "{"+Pages+"}")
+}
+
+// Resolve fills in Pages and Ratio in the file name of path
+func Resolve(path string, o Outcome) string {
+	ratio := 0.0
+	if o.OriginalSize > 0 {
+		ratio = (1 - float64(o.FinalSize)/float64(o.OriginalSize)) * 100
+	}
+	base := filepath.Base(path)
+	base = strings.ReplaceAll(base, "{"+Pages+"}", fmt.Sprint(o.Pages))
+	base = strings.ReplaceAll(base, "{"+Ratio+"}", fmt.Sprintf("%.0f", ratio))
+	return filepath.Join(filepath.Dir(path), base)
+}
+
+// Numbered returns path with a counter before the extension, e.g.
+// "report (2).pdf"
+func Numbered(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// sanitize replaces characters that would split or break a file name,
+// e.g. from a preset name
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == 0 || strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// compactSize formats n like "2.4MB" for use in file names
+func compactSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
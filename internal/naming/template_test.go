@@ -0,0 +1,140 @@
+package naming
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		goos     string
+		want     string
+	}{
+		{"empty", "  ", "linux", "empty"},
+		{"unknown placeholder", "{name}-{author}.pdf", "linux", "unknown placeholder {author}"},
+		{"unclosed brace", "{name.pdf", "linux", "unmatched {"},
+		{"stray closing brace", "name}.pdf", "linux", "unmatched }"},
+		{"closing before opening", "}{name}.pdf", "linux", "unmatched }"},
+		{"absolute", "/tmp/{name}.pdf", "linux", "relative to the output folder"},
+		{"parent folder", "../{name}.pdf", "linux", "empty or relative folder"},
+		{"empty folder", "{parent}//{name}.pdf", "linux", "empty or relative folder"},
+		{"late placeholder in folder", "{pages}/{name}.pdf", "linux", "only be used in the file name"},
+		{"windows character", "{name}?.pdf", "windows", "not allowed"},
+		{"windows reserved", "CON/{name}.pdf", "windows", "reserved name"},
+		{"windows trailing dot", "{name}.", "windows", "end in a dot"},
+		{"darwin colon", "{name}:small.pdf", "darwin", "not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(tt.template, tt.goos)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parse(%q) = %v, want error containing %q", tt.template, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseValid(t *testing.T) {
+	for _, template := range []string{
+		DefaultTemplate,
+		"{parent}/{name}-small.pdf",
+		"{name}-{pages}p-{ratio}pct.pdf",
+		"{date}_{time}_{name}",
+		"archive/{preset}/{name} ({size}).pdf",
+	} {
+		if _, err := parse(template, "windows"); err != nil {
+			t.Errorf("parse(%q): %v", template, err)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	vars := Vars{
+		Input:  filepath.Join("docs", "reports", "q1.pdf"),
+		Suffix: "_compressed",
+		Preset: "ebook/custom",
+		Time:   time.Date(2024, 1, 31, 9, 5, 7, 0, time.UTC),
+		Size:   2516582,
+	}
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{DefaultTemplate, "q1_compressed.pdf"},
+		{"{parent}/{name}", "reports/q1.pdf"},
+		{"{name}-{preset}.pdf", "q1-ebook_custom.pdf"},
+		{"{date}_{time}_{name}.PDF", "2024-01-31_090507_q1.PDF"},
+		{"{name} ({size}).pdf", "q1 (2.4MB).pdf"},
+		{"{name}-{pages}p-{ratio}.pdf", "q1-{pages}p-{ratio}.pdf"},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.template)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.template, err)
+		}
+		if got := tmpl.Expand(vars); got != filepath.FromSlash(tt.want) {
+			t.Errorf("Expand(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestUses(t *testing.T) {
+	tmpl, err := Parse("{name}-{ratio}.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tmpl.Uses(Ratio) || tmpl.Uses(Pages) {
+		t.Errorf("Uses: ratio %v, pages %v, want true, false", tmpl.Uses(Ratio), tmpl.Uses(Pages))
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := filepath.Join("out", "{pages}")
+	tests := []struct {
+		name    string
+		outcome Outcome
+		want    string
+		pending bool
+		pages   bool
+	}{
+		{"q1-{pages}p.pdf", Outcome{Pages: 12}, "q1-12p.pdf", true, true},
+		{"q1-{ratio}.pdf", Outcome{OriginalSize: 1000, FinalSize: 254}, "q1-75.pdf", true, false},
+		{"{pages}-{ratio}-{pages}.pdf", Outcome{Pages: 3, OriginalSize: 100, FinalSize: 100}, "3-0-3.pdf", true, true},
+		{"q1-{ratio}.pdf", Outcome{}, "q1-0.pdf", true, false},
+		{"q1.pdf", Outcome{Pages: 5}, "q1.pdf", false, false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if got := Pending(path); got != tt.pending {
+			t.Errorf("Pending(%q) = %v, want %v", path, got, tt.pending)
+		}
+		if got := NeedsPages(path); got != tt.pages {
+			t.Errorf("NeedsPages(%q) = %v, want %v", path, got, tt.pages)
+		}
+		// Only the file name is resolved, not the folders
+		if got := Resolve(path, tt.outcome); got != filepath.Join(dir, tt.want) {
+			t.Errorf("Resolve(%q) = %q, want %q", path, got, filepath.Join(dir, tt.want))
+		}
+	}
+}
+
+func TestNumbered(t *testing.T) {
+	tests := []struct {
+		path string
+		n    int
+		want string
+	}{
+		{"report.pdf", 2, "report (2).pdf"},
+		{filepath.Join("out", "a.b.pdf"), 10, filepath.Join("out", "a.b (10).pdf")},
+		{"noext", 3, "noext (3)"},
+	}
+	for _, tt := range tests {
+		if got := Numbered(tt.path, tt.n); got != tt.want {
+			t.Errorf("Numbered(%q, %d) = %q, want %q", tt.path, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+)
+
+// DefaultOutputDir is the folder name used for outputs next to the input
+const DefaultOutputDir = "compressed"
+
+// DefaultWorkers is the number of directories read in parallel
+const DefaultWorkers = 8
+
+// progressInterval is how often Scanner.OnProgress is called
+const progressInterval = 100 * time.Millisecond
+
+// pdfHeader must appear in the first headerSize bytes of a PDF
+var pdfHeader = []byte("%PDF-")
+
+const headerSize = 1024
+
+// Options filters the files found in a folder
+type Options struct {
+	// Include lists glob patterns a file name must match. Patterns
+	// without a slash match the file name, patterns with one match the
+	// path relative to the scanned folder. Matching is case insensitive.
+	// If empty, every file is a candidate with DetectContent and
+	// "*.pdf" is used otherwise.
+	Include []string
+	// Exclude lists patterns for files and folders to leave out; a
+	// matching folder is not entered
+	Exclude []string
+	// DetectContent recognises PDFs by their %PDF- header instead of
+	// trusting the file extension
+	DetectContent bool
+	// MaxDepth limits how deep folders are entered (0 = unlimited,
+	// 1 = only the files directly in the folder)
+	MaxDepth int
+	// SkipHidden leaves out files and folders starting with a dot
+	SkipHidden bool
+	// SkipOutputDirs leaves out "compressed" folders and OutputDirs
+	SkipOutputDirs bool
+	OutputDirs     []string
+	// MinSize and MaxSize bound the file size in bytes (0 = no bound)
+	MinSize int64
+	MaxSize int64
+	// ModifiedSince leaves out files last modified before it (zero = any)
+	ModifiedSince time.Time
+	// FollowSymlinks enters linked folders and includes linked files;
+	// otherwise symlinks are ignored
+	FollowSymlinks bool
+	// Suffix marks outputs of this app (see SkipOwnOutputs)
+	Suffix string
+	// SkipOwnOutputs leaves out files whose name ends in Suffix or that
+	// carry this app's stamp. Outputs written with MetadataStrip have no
+	// stamp, so they are only recognised by their name.
+	SkipOwnOutputs bool
+}
+
+// PathError is a file or folder the scan could not read
+type PathError struct {
+	Path string
+	Err  error
+}
+
+// Result lists the files a scan matched, sorted by path
+type Result struct {
+	Files      []string
+	Filtered   int // files left out by the filters
+	Skipped    int // outputs of this app left out by SkipOwnOutputs
+	Unreadable []PathError
+}
+
+// Scanner finds PDFs in a folder tree, reading several directories at
+// once. It is safe to reuse for several scans.
+type Scanner struct {
+	Options
+	// Workers is the number of directories and files read in parallel
+	// (0 means DefaultWorkers)
+	Workers int
+	// OnProgress, if set, is called periodically from a single goroutine
+	// with the number of PDFs found and folders read so far
+	OnProgress func(found, dirs int)
+}
+
+// Folder scans root with opts
+func Folder(root string, opts Options) (Result, error) {
+	s := Scanner{Options: opts}
+	return s.Scan(context.Background(), root)
+}
+
+// Scan walks root and returns the files that pass the options. Folders
+// and files that cannot be read are listed in Result.Unreadable; only an
+// unreadable root is an error. When ctx is cancelled the scan stops and
+// returns what it found so far with ctx.Err().
+func (s *Scanner) Scan(ctx context.Context, root string) (Result, error) {
+	root = filepath.Clean(root)
+	info, err := os.Stat(root)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return Result{}, fmt.Errorf("failed to scan %s: not a folder", root)
+	}
+
+	workers := s.Workers
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+	w := &walker{
+		ctx:     ctx,
+		opts:    s.Options,
+		sem:     make(chan struct{}, workers),
+		visited: make(map[string]bool),
+	}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		w.visited[real] = true
+	}
+
+	// Report progress from one goroutine while the walk runs
+	stop := make(chan struct{})
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.report(s.OnProgress)
+			case <-stop:
+				w.report(s.OnProgress)
+				return
+			}
+		}
+	}()
+
+	w.wg.Add(1)
+	go w.walk(root, "", 0)
+	w.wg.Wait()
+	close(stop)
+	<-reported
+
+	sort.Strings(w.res.Files)
+	sort.Slice(w.res.Unreadable, func(i, j int) bool {
+		return w.res.Unreadable[i].Path < w.res.Unreadable[j].Path
+	})
+	return w.res, ctx.Err()
+}
+
+type walker struct {
+	ctx  context.Context
+	opts Options
+	sem  chan struct{} // bounds concurrent reads
+	wg   sync.WaitGroup
+
+	found atomic.Int64
+	dirs  atomic.Int64
+
+	mu      sync.Mutex
+	res     Result
+	visited map[string]bool // real paths of folders entered, to stop symlink loops
+}
+
+func (w *walker) report(onProgress func(found, dirs int)) {
+	if onProgress != nil {
+		onProgress(int(w.found.Load()), int(w.dirs.Load()))
+	}
+}
+
+// walk scans dir, whose path relative to the root is rel, at depth.
+// Subfolders are walked in their own goroutines.
+func (w *walker) walk(dir, rel string, depth int) {
+	defer w.wg.Done()
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	w.sem <- struct{}{}
+	entries, err := os.ReadDir(dir)
+	<-w.sem
+	w.dirs.Add(1)
+	if err != nil {
+		w.unreadable(dir, err)
+		if len(entries) == 0 {
+			return
+		}
+	}
+
+	for _, e := range entries {
+		if w.ctx.Err() != nil {
+			return
+		}
+		p := filepath.Join(dir, e.Name())
+		r := path.Join(rel, e.Name())
+
+		isDir := e.IsDir()
+		if e.Type()&fs.ModeSymlink != 0 {
+			if !w.opts.FollowSymlinks {
+				continue
+			}
+			info, err := os.Stat(p)
+			if err != nil {
+				continue // dangling link
+			}
+			isDir = info.IsDir()
+		}
+
+		if isDir {
+			if w.enterDir(p, r, depth+1) {
+				w.wg.Add(1)
+				go w.walk(p, r, depth+1)
+			}
+			continue
+		}
+		w.checkFile(p, r)
+	}
+}
+
+func (w *walker) enterDir(p, rel string, depth int) bool {
+	name := filepath.Base(p)
+	if w.opts.MaxDepth > 0 && depth >= w.opts.MaxDepth {
+		return false
+	}
+	if w.opts.SkipHidden && strings.HasPrefix(name, ".") {
+		return false
+	}
+	if w.opts.SkipOutputDirs && w.isOutputDir(p) {
+		return false
+	}
+	if matchAny(w.opts.Exclude, name, rel) {
+		return false
+	}
+
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		w.unreadable(p, err)
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.visited[real] {
+		return false
+	}
+	w.visited[real] = true
+	return true
+}
+
+func (w *walker) isOutputDir(p string) bool {
+	if strings.EqualFold(filepath.Base(p), DefaultOutputDir) {
+		return true
+	}
+	for _, dir := range w.opts.OutputDirs {
+		if filepath.Clean(dir) == p {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFile applies the filters to one file. Content is checked before
+// the filters so that only PDFs count as filtered out.
+func (w *walker) checkFile(p, rel string) {
+	name := filepath.Base(p)
+	include := w.opts.Include
+	if len(include) == 0 && !w.opts.DetectContent {
+		include = []string{"*.pdf"}
+	}
+	if len(include) > 0 && !matchAny(include, name, rel) {
+		return // not a candidate at all
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		w.unreadable(p, err)
+		return
+	}
+	if w.opts.DetectContent {
+		isPDF, err := w.sniff(p)
+		if err != nil {
+			w.unreadable(p, err)
+			return
+		}
+		if !isPDF {
+			return
+		}
+	}
+
+	if (w.opts.SkipHidden && strings.HasPrefix(name, ".")) ||
+		matchAny(w.opts.Exclude, name, rel) ||
+		(w.opts.MinSize > 0 && info.Size() < w.opts.MinSize) ||
+		(w.opts.MaxSize > 0 && info.Size() > w.opts.MaxSize) ||
+		(!w.opts.ModifiedSince.IsZero() && info.ModTime().Before(w.opts.ModifiedSince)) {
+		w.mu.Lock()
+		w.res.Filtered++
+		w.mu.Unlock()
+		return
+	}
+	if w.opts.SkipOwnOutputs && isOwnOutput(p, w.opts.Suffix) {
+		w.mu.Lock()
+		w.res.Skipped++
+		w.mu.Unlock()
+		return
+	}
+
+	w.found.Add(1)
+	w.mu.Lock()
+	w.res.Files = append(w.res.Files, p)
+	w.mu.Unlock()
+}
+
+// sniff reports whether p starts with a PDF header
+func (w *walker) sniff(p string) (bool, error) {
+	w.sem <- struct{}{}
+	defer func() { <-w.sem }()
+
+	f, err := os.Open(p)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, headerSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return bytes.Contains(head[:n], pdfHeader), nil
+}
+
+func (w *walker) unreadable(p string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.res.Unreadable = append(w.res.Unreadable, PathError{Path: p, Err: err})
+}
+
+// matchAny reports whether a pattern matches name or, for patterns with
+// a slash, the relative path
+func matchAny(patterns []string, name, rel string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		target := strings.ToLower(name)
+		if strings.Contains(pattern, "/") {
+			target = strings.ToLower(rel)
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isOwnOutput reports whether p looks like an output of this app
+func isOwnOutput(p, suffix string) bool {
+	base := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	if suffix != "" && strings.HasSuffix(base, suffix) {
+		return true
+	}
+	_, stamped, _ := compression.ReadStamp(p)
+	return stamped
+}
+
+// SplitPatterns splits a comma or semicolon separated list of patterns
+func SplitPatterns(s string) []string {
+	var patterns []string
+	for _, p := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
@@ -1,11 +1,11 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 	"runtime"
-	"strings"
+	"sort"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -15,46 +15,283 @@ import (
 	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/widget"
 
-	"simplepdfcompress/internal/compression"
-	"simplepdfcompress/internal/worker"
+	"github.com/thelaonerd/simplepdfcompress/internal/cache"
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+	"github.com/thelaonerd/simplepdfcompress/internal/journal"
+	"github.com/thelaonerd/simplepdfcompress/internal/scan"
+	"github.com/thelaonerd/simplepdfcompress/internal/worker"
+	"github.com/thelaonerd/simplepdfcompress/pkg/spc"
 
 	"github.com/ncruces/zenity"
 )
 
 func createBatchFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject {
 	var inputFiles []string
+	inputRoots := make(map[string]string) // folder each input was scanned from
 	var outputFolderURI fyne.URI
 
+	// Running batch state (only touched on the UI goroutine)
+	var activePool *worker.Pool
+	var activeJournal *journal.Journal
+	var activeJobs []worker.Job   // jobs submitted to activePool
+	var resumeBox *fyne.Container // shown when an interrupted batch can be resumed
+	var completed, total int
+
 	// UI Elements
 	fileListLabel := widget.NewLabel("No files added")
 	fileListLabel.Wrapping = fyne.TextWrapWord
 
 	outputLabel := widget.NewLabel("Default output: ./compressed (relative to each file)")
 	outputLabel.Wrapping = fyne.TextWrapBreak
+	mirrorCheck := widget.NewCheck("Keep the folder structure of added folders", nil)
+	mirrorCheck.SetChecked(true)
+	clashSelect := widget.NewSelect([]string{clashNumber, clashStop}, nil)
+	clashSelect.SetSelected(clashNumber)
+	conflictSelect := createConflictSelect()
 
 	qualitySelect := createQualitySelect()
 
+	retrySelect := createRetrySelect()
+	shrinkThreshold, shrinkAction := createShrinkSelects()
+	timeoutSelect := createTimeoutSelect()
+	memoryLimitSelect := createMemoryLimitSelect()
+	priorityCheck := createPriorityCheck()
+	preserveFiles, preserveDates := createPreserveChecks()
+	metadataSelect := createMetadataSelect()
+	sanitize := newSanitizeControls()
+	signedSelect := createSignedSelect()
+
+	// Scheduling: applied to the running pool immediately
+	scheduleSelect := widget.NewSelect([]string{scheduleLargest, scheduleFIFO, scheduleShortest}, func(choice string) {
+		if activePool != nil {
+			activePool.SetSchedule(parseSchedule(choice))
+		}
+	})
+	scheduleSelect.SetSelected(scheduleLargest)
+	budgetSelect := widget.NewSelect([]string{budgetUnlimited, "256 MB", "512 MB", "1 GB", "2 GB", "4 GB"}, func(choice string) {
+		if activePool != nil {
+			activePool.SetMemoryBudget(parseSize(choice))
+		}
+	})
+	budgetSelect.SetSelected(budgetUnlimited)
+	// Result cache: unchanged inputs reuse their previous output
+	cacheCheck := widget.NewCheck("Skip unchanged files", nil)
+	cacheCheck.SetChecked(true)
+	forceCheck := widget.NewCheck("Force recompress", nil)
+	cacheSizeSelect := widget.NewSelect([]string{"1 GB", "5 GB", "20 GB"}, nil)
+	cacheSizeSelect.SetSelected("5 GB")
+	clearCacheBtn := widget.NewButton("Clear Cache", func() {
+		c, err := openCache(0)
+		if err == nil {
+			err = c.Clear()
+			c.Close()
+		}
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to clear cache: %w", err), w)
+			return
+		}
+		dialog.ShowInformation("Cache", "The result cache was cleared.", w)
+	})
+	reuseCheck := widget.NewCheck("Reuse Ghostscript processes (faster for many small files)", func(on bool) {
+		if activePool != nil {
+			activePool.SetReuseProcesses(on)
+		}
+	})
+
 	maxThreads := float64(runtime.NumCPU())
 	threadSlider := widget.NewSlider(1, maxThreads)
 	threadSlider.Value = maxThreads
 	threadLabel := widget.NewLabel(fmt.Sprintf("Threads: %d", int(maxThreads)))
 	threadSlider.OnChanged = func(f float64) {
 		threadLabel.SetText(fmt.Sprintf("Threads: %d", int(f)))
+		if activePool != nil {
+			activePool.Resize(int(f))
+		}
 	}
 
 	suffixEntry := createSuffixEntry()
+	templateEntry, templatePreview := createTemplateEntry()
+	refreshPreview := func() {
+		input, outDir := "", ""
+		if len(inputFiles) > 0 {
+			input = inputFiles[0]
+		}
+		if outputFolderURI != nil {
+			outDir = outputFolderURI.Path()
+		}
+		templatePreview.SetText(previewOutput(templateEntry.Text, input, outDir, suffixEntry.Text, qualitySelect.Selected))
+	}
+	templateEntry.OnChanged = func(string) { refreshPreview() }
+	suffixEntry.OnChanged = func(string) { refreshPreview() }
+	qualitySelect.OnChanged = func(string) { refreshPreview() }
+	refreshPreview()
+	filters := newScanFilters()
 
 	progressBar := widget.NewProgressBar()
 	progressBar.Hide()
 
 	statusLabel := widget.NewLabel("")
 
+	inProgressLabel := widget.NewLabel("")
+	inProgressLabel.Wrapping = fyne.TextWrapWord
+
 	logEntry := widget.NewMultiLineEntry()
 	logEntry.Disable() // Read-only log
 	logEntry.SetMinRowsVisible(8)
 	logScroll := container.NewVScroll(logEntry)
 	logScroll.SetMinSize(fyne.NewSize(0, 150))
 
+	appendLog := func(s string) {
+		logEntry.SetText(logEntry.Text + s)
+	}
+
+	showProgress := func(paused bool) {
+		progressBar.SetValue(float64(completed) / float64(total))
+		if paused {
+			statusLabel.SetText(fmt.Sprintf("Paused (%d/%d processed)", completed, total))
+		} else {
+			statusLabel.SetText(fmt.Sprintf("Processed %d/%d", completed, total))
+		}
+	}
+
+	// currentSettings reads the job settings from the widgets. It must be
+	// called on the UI goroutine.
+	currentSettings := func() jobSettings {
+		opts := compression.CompressionOptions{Quality: qualitySelect.Selected, Stamp: true}
+		applyLimits(&opts, timeoutSelect.Selected, memoryLimitSelect.Selected, priorityCheck.Checked)
+		applyPreserve(&opts, preserveFiles.Checked, preserveDates.Checked)
+		opts.Metadata = metadataModes[metadataSelect.Selected]
+		sanitize.apply(&opts)
+
+		settings := jobSettings{
+			opts:      opts,
+			template:  templateEntry.Text,
+			suffix:    suffixEntry.Text,
+			mirror:    mirrorCheck.Checked,
+			clashStop: clashSelect.Selected == clashStop,
+			retry:     retryPolicy(retrySelect.Selected, opts),
+			conflict:  conflictPolicies[conflictSelect.Selected],
+			shrink:    shrinkPolicy(shrinkThreshold.Selected, shrinkAction.Selected),
+			signature: signaturePolicies[signedSelect.Selected],
+		}
+		if outputFolderURI != nil {
+			settings.outDir = outputFolderURI.Path()
+		}
+		return settings
+	}
+
+	// prepareJobs builds jobs for files with settings. roots maps files
+	// added from a folder to that folder. Outputs that would clash are
+	// numbered or reported as an error; existing outputs are left to the
+	// conflict policy. It does not touch the widgets, so it may run in
+	// the background.
+	prepareJobs := func(files []string, roots map[string]string, settings jobSettings) ([]worker.Job, error) {
+		jobs := make([]worker.Job, 0, len(files))
+		opts := settings.opts
+		tmpl, err := outputTemplate(settings.template)
+		if err != nil {
+			return nil, err
+		}
+
+		// 1. Work out the outputs
+		start := time.Now()
+		outputs := make([]string, len(files))
+		for i, file := range files {
+			root := ""
+			if settings.mirror {
+				root = roots[file]
+			}
+			vars := templateVars(file, settings.suffix, opts.Quality, start)
+			outputs[i] = filepath.Join(spc.OutputDir(file, root, settings.outDir), tmpl.Expand(vars))
+		}
+
+		// 2. Resolve clashes
+		if clashes := spc.Collisions(outputs); len(clashes) > 0 {
+			if settings.clashStop {
+				return nil, clashError(files, outputs, clashes)
+			}
+			outputs = spc.Dedupe(outputs)
+		}
+
+		// 3. Build the jobs
+		for i, file := range files {
+			jobs = append(jobs, worker.Job{
+				InputPath:  file,
+				OutputPath: outputs[i],
+				Options:    opts,
+				Retry:      settings.retry,
+				Conflict:   settings.conflict,
+				Shrink:     settings.shrink,
+				Signature:  settings.signature,
+			})
+		}
+		return jobs, nil
+	}
+
+	// submitToRunningBatch adds files to the batch that is currently running.
+	// If it finished in the meantime, the files go to the list for the next
+	// run. It must be called on the UI goroutine.
+	submitToRunningBatch := func(files []string, roots map[string]string) {
+		settings := currentSettings()
+		go func() {
+			jobs, err := prepareJobs(files, roots, settings)
+			if err != nil {
+				fyne.Do(func() {
+					appendLog(fmt.Sprintf("Skipped %d added files: %v\n", len(files), err))
+				})
+				return
+			}
+
+			fyne.Do(func() {
+				if activePool == nil {
+					inputFiles = append(inputFiles, files...)
+					for file, root := range roots {
+						inputRoots[file] = root
+					}
+					updateFileListLabel(fileListLabel, inputFiles)
+					return
+				}
+				// Outputs queued earlier may have the same names
+				if err := resolveLateClashes(activeJobs, jobs, settings.clashStop); err != nil {
+					appendLog(fmt.Sprintf("Skipped %d added files: %v\n", len(files), err))
+					return
+				}
+				if activeJournal != nil {
+					activeJournal.Add(jobs...)
+				}
+				for _, job := range jobs {
+					if activePool.Submit(job) == nil {
+						activeJobs = append(activeJobs, job)
+						total++
+					}
+				}
+				showProgress(activePool.Paused())
+				appendLog(fmt.Sprintf("Added %d files to the running batch.\n", len(jobs)))
+			})
+		}()
+	}
+
+	// addInputFiles must be called on the UI goroutine. root is the folder
+	// the files were found in, or "" for individually added files.
+	addInputFiles := func(files []string, root string) {
+		roots := make(map[string]string)
+		if root != "" {
+			for _, file := range files {
+				roots[file] = root
+			}
+		}
+		if activePool != nil {
+			submitToRunningBatch(files, roots)
+			return
+		}
+		inputFiles = append(inputFiles, files...)
+		for file, root := range roots {
+			inputRoots[file] = root
+		}
+		updateFileListLabel(fileListLabel, inputFiles)
+		refreshPreview()
+	}
+
 	// Buttons
 	var compressBtn *widget.Button
 
@@ -67,8 +304,7 @@ func createBatchFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 			)
 			if err == nil {
 				fyne.Do(func() {
-					inputFiles = append(inputFiles, filenames...)
-					updateFileListLabel(fileListLabel, inputFiles)
+					addInputFiles(filenames, "")
 				})
 				return
 			}
@@ -80,8 +316,7 @@ func createBatchFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 						if err != nil || reader == nil {
 							return
 						}
-						inputFiles = append(inputFiles, reader.URI().Path())
-						updateFileListLabel(fileListLabel, inputFiles)
+						addInputFiles([]string{reader.URI().Path()}, "")
 					}, w)
 					fd.SetFilter(storage.NewExtensionFileFilter([]string{".pdf"}))
 					fd.Show()
@@ -91,42 +326,46 @@ func createBatchFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 	})
 
 	addFolderBtn := widget.NewButton("Add Folder", func() {
+		suffix := suffixEntry.Text
+		if suffix == "" {
+			suffix = spc.DefaultSuffix
+		}
+		outDirPath := ""
+		if outputFolderURI != nil {
+			outDirPath = outputFolderURI.Path()
+		}
+		scanOpts, err := filters.options(suffix, outDirPath)
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+
+		// scanAndPreview scans dir in the background and shows what matched
+		scanAndPreview := func(dir string) {
+			runFolderScan(w, dir, scanOpts, func(res scan.Result) {
+				if res.Skipped > 0 {
+					appendLog(fmt.Sprintf("Skipped %d already compressed files in %s\n", res.Skipped, dir))
+				}
+				for _, u := range res.Unreadable {
+					appendLog(fmt.Sprintf("Could not read %s: %v\n", u.Path, u.Err))
+				}
+				if len(res.Files) == 0 {
+					dialog.ShowInformation("No PDFs Found", fmt.Sprintf("No PDF files to compress were found in the selected folder (%d filtered out, %d already compressed, %d unreadable).", res.Filtered, res.Skipped, len(res.Unreadable)), w)
+					return
+				}
+				showScanPreview(w, dir, res, func() {
+					addInputFiles(res.Files, dir)
+				})
+			})
+		}
+
 		go func() {
 			directory, err := zenity.SelectFile(
 				zenity.Title("Select Folder"),
 				zenity.Directory(),
 			)
 			if err == nil {
-				// Scan folder for PDFs
-				var pdfs []string
-				err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
-					if err != nil {
-						return err
-					}
-					if !info.IsDir() && strings.HasSuffix(strings.ToLower(info.Name()), ".pdf") {
-						pdfs = append(pdfs, path)
-					}
-					return nil
-				})
-
-				if err != nil {
-					fyne.Do(func() {
-						dialog.ShowError(fmt.Errorf("failed to scan folder: %w", err), w)
-					})
-					return
-				}
-
-				if len(pdfs) > 0 {
-					fyne.Do(func() {
-						inputFiles = append(inputFiles, pdfs...)
-						updateFileListLabel(fileListLabel, inputFiles)
-						dialog.ShowInformation("Folder Added", fmt.Sprintf("Added %d PDF files from folder.", len(pdfs)), w)
-					})
-				} else {
-					fyne.Do(func() {
-						dialog.ShowInformation("No PDFs Found", "No PDF files were found in the selected folder.", w)
-					})
-				}
+				scanAndPreview(directory)
 				return
 			}
 
@@ -134,25 +373,15 @@ func createBatchFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 				// Fallback to Fyne Folder Dialog
 				fyne.Do(func() {
 					fd := dialog.NewFolderOpen(func(uri fyne.ListableURI, err error) {
-						if err != nil || uri == nil {
+						if err != nil {
+							dialog.ShowError(err, w)
 							return
 						}
-						// Scan folder (Fyne URI)
-						// Note: Walking Fyne URIs recursively is cleaner with Fyne storage API or converting to path if local.
-						// Since we target local mostly, let's try path.
-						dirPath := uri.Path()
-						var pdfs []string
-						filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
-							if err == nil && !info.IsDir() && strings.HasSuffix(strings.ToLower(info.Name()), ".pdf") {
-								pdfs = append(pdfs, path)
-							}
-							return nil
-						})
-
-						if len(pdfs) > 0 {
-							inputFiles = append(inputFiles, pdfs...)
-							updateFileListLabel(fileListLabel, inputFiles)
+						if uri == nil {
+							return
 						}
+						// Since we target local mostly, scan the path behind the URI
+						scanAndPreview(uri.Path())
 					}, w)
 					fd.Show()
 				})
@@ -160,213 +389,452 @@ func createBatchFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 		}()
 	})
 
+	// Pausing stops new files from starting; on Unix the running
+	// Ghostscript processes can be suspended too.
+	suspendCheck := widget.NewCheck("Also suspend files in progress", nil)
+	suspendCheck.SetChecked(true)
+	if !compression.CanSuspend() {
+		suspendCheck.Hide()
+	}
+
+	var pauseBtn *widget.Button
+	pauseBtn = widget.NewButton("Pause", func() {
+		if activePool == nil {
+			return
+		}
+		if activePool.Paused() {
+			activePool.Resume()
+			pauseBtn.SetText("Pause")
+			progressBar.TextFormatter = nil
+			showProgress(false)
+			appendLog("Resumed.\n")
+		} else {
+			activePool.Pause(suspendCheck.Checked)
+			pauseBtn.SetText("Resume")
+			progressBar.TextFormatter = func() string {
+				return fmt.Sprintf("Paused - %.0f%%", progressBar.Value*100)
+			}
+			showProgress(true)
+			appendLog("Paused.\n")
+		}
+		progressBar.Refresh()
+	})
+	pauseBtn.Disable()
+
 	clearFilesBtn := widget.NewButton("Clear List", func() {
 		inputFiles = []string{}
+		inputRoots = make(map[string]string)
 		updateFileListLabel(fileListLabel, inputFiles)
 		logEntry.SetText("")
+		refreshPreview()
 	})
 
 	selectOutputBtn := widget.NewButton("Select Output Folder (Optional)", func() {
 		selectFolder(w, "Select Output Folder", func(uri fyne.URI) {
 			outputFolderURI = uri
 			outputLabel.SetText(uri.Path())
+			refreshPreview()
 		})
 	})
 
-	compressBtn = widget.NewButton("Compress All", func() {
-		if len(inputFiles) == 0 {
-			dialog.ShowInformation("Info", "Please add files first", w)
-			return
-		}
-
-		// Disable interactions
+	// beginRun disables the form while a batch runs (files can still be added)
+	beginRun := func(numFiles int) {
+		resumeBox.Hide() // a new run replaces the previous journal
 		compressBtn.Disable()
-		addFilesBtn.Disable()
-		addFolderBtn.Disable()
 		clearFilesBtn.Disable()
 		selectOutputBtn.Disable()
+		mirrorCheck.Disable()
+		clashSelect.Disable()
+		conflictSelect.Disable()
 		qualitySelect.Disable()
+		retrySelect.Disable()
+		shrinkThreshold.Disable()
+		shrinkAction.Disable()
+		preserveFiles.Disable()
+		preserveDates.Disable()
+		metadataSelect.Disable()
+		sanitize.setEnabled(false)
+		signedSelect.Disable()
+		timeoutSelect.Disable()
+		memoryLimitSelect.Disable()
+		priorityCheck.Disable()
+		cacheCheck.Disable()
+		forceCheck.Disable()
+		cacheSizeSelect.Disable()
+		clearCacheBtn.Disable()
 		suffixEntry.Disable()
-		// threadSlider.SetValue(threadSlider.Value) // Hack to keep visual state? No, SetValue doesn't disable.
-		// There is no Disable() on slider in older Fyne versions easily exposed?
-		// Actually widget.Slider has Disable().
-		// Let's assume it does.
-		// threadSlider.Disable() // Assuming available
+		templateEntry.Disable()
 		onStart()
 
 		progressBar.Show()
 		progressBar.SetValue(0)
-		statusLabel.SetText(fmt.Sprintf("Starting compression of %d files...", len(inputFiles)))
+		statusLabel.SetText(fmt.Sprintf("Starting compression of %d files...", numFiles))
 		logEntry.SetText("Starting batch compression...\n")
+	}
 
-		go func() {
-			defer fyne.Do(func() {
-				compressBtn.Enable()
-				addFilesBtn.Enable()
-				addFolderBtn.Enable()
-				clearFilesBtn.Enable()
-				selectOutputBtn.Enable()
-				qualitySelect.Enable()
-				suffixEntry.Enable()
-				// threadSlider.Enable()
-				onEnd()
-			})
+	endRun := func() {
+		inProgressLabel.SetText("")
+		activePool = nil
+		activeJournal = nil
+		activeJobs = nil
+		pauseBtn.SetText("Pause")
+		pauseBtn.Disable()
+		progressBar.TextFormatter = nil
+		progressBar.Refresh()
+		compressBtn.Enable()
+		clearFilesBtn.Enable()
+		selectOutputBtn.Enable()
+		mirrorCheck.Enable()
+		clashSelect.Enable()
+		conflictSelect.Enable()
+		qualitySelect.Enable()
+		retrySelect.Enable()
+		shrinkThreshold.Enable()
+		shrinkAction.Enable()
+		preserveFiles.Enable()
+		preserveDates.Enable()
+		metadataSelect.Enable()
+		sanitize.setEnabled(true)
+		signedSelect.Enable()
+		timeoutSelect.Enable()
+		memoryLimitSelect.Enable()
+		priorityCheck.Enable()
+		cacheCheck.Enable()
+		forceCheck.Enable()
+		cacheSizeSelect.Enable()
+		clearCacheBtn.Enable()
+		suffixEntry.Enable()
+		templateEntry.Enable()
+		onEnd()
+	}
 
-			// 1. Prepare Jobs & Check Overwrites
-			jobs := make([]worker.Job, 0, len(inputFiles))
-			opts := compression.CompressionOptions{Quality: qualitySelect.Selected}
+	// runBatch compresses jobs in the background, recording progress in jnl
+	// (which may be nil if the journal could not be written)
+	runBatch := func(jobs []worker.Job, jnl *journal.Journal, numWorkers int) {
+		// 2. Start Pool (more files may be submitted while it runs)
+		startTime := time.Now()
+		pool := worker.NewPool(numWorkers)
+		useCache, force, cacheSize := false, false, int64(0)
+		fyne.DoAndWait(func() {
+			pool.SetSchedule(parseSchedule(scheduleSelect.Selected))
+			pool.SetMemoryBudget(parseSize(budgetSelect.Selected))
+			pool.SetReuseProcesses(reuseCheck.Checked)
+			pool.SetAskFunc(newConflictAsker(w))
+			pool.SetConfirmSignedFunc(newSignedConfirmer(w))
+			useCache, force, cacheSize = cacheCheck.Checked, forceCheck.Checked, parseSize(cacheSizeSelect.Selected)
+		})
+		if useCache {
+			if c, err := openCache(cacheSize); err == nil {
+				defer c.Close()
+				pool.SetCache(c, force)
+			} else {
+				fyne.Do(func() {
+					appendLog(fmt.Sprintf("Result cache unavailable: %v\n", err))
+				})
+			}
+		}
+		for _, job := range jobs {
+			pool.Submit(job)
+		}
+		fyne.Do(func() {
+			activePool = pool
+			activeJournal = jnl
+			activeJobs = jobs
+			completed = 0
+			total = len(jobs)
+			pauseBtn.Enable()
+		})
 
-			// Map to check overwrites
-			var overwriteCandidates []string
+		var successes, failures, cached, skipped int
+		var notShrunk int // outputs that saved less than the shrink policy asks
+		var sanitized int // outputs that had content removed
+		var removed compression.ActiveContent
+		var signedSkipped, signedCompressed int // digitally signed inputs
+
+		// Files currently being compressed, keyed by input path
+		inProgress := make(map[string]worker.Event)
+		var lastProgressUpdate time.Time
+
+		for ev := range pool.Events() {
+			switch ev.Type {
+			case worker.EventStarted, worker.EventProgress:
+				inProgress[ev.Job.InputPath] = ev
+				// Page events can be very frequent, refresh at most 10x per second
+				if ev.Type == worker.EventProgress && time.Since(lastProgressUpdate) < 100*time.Millisecond {
+					continue
+				}
+				lastProgressUpdate = time.Now()
+				text := formatInProgress(inProgress)
+				fyne.Do(func() {
+					inProgressLabel.SetText(text)
+				})
+				continue
+			case worker.EventRetrying:
+				how := "retrying"
+				if ev.Fallback != "" {
+					how = "retrying " + ev.Fallback
+				}
+				logMsg := fmt.Sprintf("[~] %s: Attempt %d failed (%v), %s\n", filepath.Base(ev.Job.InputPath), ev.Attempt, ev.Error, how)
+				fyne.Do(func() {
+					appendLog(logMsg)
+				})
+				continue
+			case worker.EventFinished, worker.EventFailed, worker.EventCancelled:
+				delete(inProgress, ev.Job.InputPath)
+			default:
+				continue
+			}
 
-			// Get suffix
-			suffix := suffixEntry.Text
-			if suffix == "" {
-				suffix = "_spc_compressed"
+			res := ev.Result
+			if jnl != nil && ev.Type != worker.EventCancelled {
+				jnl.Record(res)
 			}
 
-			for _, file := range inputFiles {
-				outDirPath := ""
-				if outputFolderURI != nil {
-					outDirPath = outputFolderURI.Path()
+			var logMsg string
+			if ev.Type == worker.EventCancelled {
+				failures++
+				logMsg = fmt.Sprintf("[-] %s: Cancelled\n", filepath.Base(res.Job.InputPath))
+			} else if res.Error != nil {
+				failures++
+				logMsg = fmt.Sprintf("[X] %s: Failed after %s - %v\n", filepath.Base(res.Job.InputPath), res.Duration.Round(time.Millisecond), res.Error)
+				if compression.IsLimitExceeded(res.Error) {
+					logMsg += "    -> Stopped by the time/memory limit. Raise it to process this file.\n"
+				}
+			} else if res.Signature == worker.SignedSkipped {
+				signedSkipped++
+				logMsg = fmt.Sprintf("[S] %s: Skipped, digitally signed\n", filepath.Base(res.Job.InputPath))
+			} else if res.Conflict == worker.Skipped {
+				skipped++
+				logMsg = fmt.Sprintf("[>] %s: Skipped, output exists (%s)\n", filepath.Base(res.Job.InputPath), res.OutputPath)
+			} else {
+				successes++
+				// Calculate Ratio
+				// (1 - Compressed/Original) * 100
+				// If Compressed > Original, Ratio is negative.
+				ratio := spc.Ratio(res.OriginalSize, res.FinalSize)
+
+				logMsg = fmt.Sprintf("[O] %s: Ratio: %.1f%% (%s -> %s) in %s\n",
+					filepath.Base(res.Job.InputPath), ratio,
+					formatBytes(res.OriginalSize), formatBytes(res.FinalSize),
+					res.Duration.Round(time.Millisecond))
+				if res.Cached {
+					cached++
+					logMsg = fmt.Sprintf("[=] %s: Unchanged, reused cached result. Ratio: %.1f%% (%s -> %s)\n",
+						filepath.Base(res.Job.InputPath), ratio,
+						formatBytes(res.OriginalSize), formatBytes(res.FinalSize))
+				} else if res.Fallback != "" {
+					logMsg += fmt.Sprintf("    -> Succeeded on attempt %d with fallback: %s\n", res.Attempts, res.Fallback)
+				} else if res.Attempts > 1 {
+					logMsg += fmt.Sprintf("    -> Succeeded on attempt %d\n", res.Attempts)
+				}
+
+				switch res.Conflict {
+				case worker.Overwrote:
+					logMsg += "    -> Replaced the existing output\n"
+				case worker.Renamed:
+					logMsg += fmt.Sprintf("    -> Output existed, saved as %s\n", filepath.Base(res.OutputPath))
 				}
-				outFile := GenerateOutputPath(file, outDirPath, suffixEntry.Text)
 
-				if _, err := os.Stat(outFile); err == nil {
-					overwriteCandidates = append(overwriteCandidates, outFile)
+				if res.Job.Options.Sanitize.Enabled() {
+					logMsg += "    -> " + describeRemoved(res.Removed, res.Left, res.Job.Options.Sanitize) + "\n"
+					if !res.Removed.IsZero() {
+						sanitized++
+						removed = removed.Add(res.Removed)
+					}
 				}
 
-				jobs = append(jobs, worker.Job{
-					InputPath:  file,
-					OutputPath: outFile,
-					Options:    opts,
-				})
+				if res.Signature == worker.SignedCompressed {
+					signedCompressed++
+					logMsg += "    -> Digitally signed: the signature is not valid in the output\n"
+				}
+
+				if res.NotShrunk {
+					notShrunk++
+					logMsg += "    -> " + describeShrink(res.Job.Shrink) + "\n"
+				}
 			}
 
-			// Ask permission if files exist
-			if len(overwriteCandidates) > 0 {
-				// Blocking dialog needs to be on Main? No, Zenity is fine in goroutine usually,
-				// but let's check if we want Fyne dialog.
-				// Zenity question:
-				err := zenity.Question(
-					fmt.Sprintf("Found %d existing files that will be overwritten. Continue?", len(overwriteCandidates)),
-					zenity.Title("Overwrite Confirmation"),
-					zenity.OKLabel("Overwrite"),
-					zenity.CancelLabel("Cancel"),
-				)
-				if err != nil { // err is not nil if cancelled or error
-					// Cancelled
-					fyne.Do(func() {
-						statusLabel.SetText("Cancelled.")
-						logEntry.SetText(logEntry.Text + "\nCancelled by user.")
-						progressBar.SetValue(0)
-					})
-					return
+			text := formatInProgress(inProgress)
+			fyne.Do(func() {
+				completed++
+				showProgress(pool.Paused())
+				appendLog(logMsg)
+				inProgressLabel.SetText(text)
+
+				// Everything submitted so far is done: stop accepting new jobs
+				if completed == total {
+					activePool = nil
+					activeJournal = nil
+					activeJobs = nil
+					pauseBtn.Disable()
+					pool.Close()
 				}
+			})
+		}
+
+		// The batch finished, nothing left to resume
+		if jnl != nil {
+			jnl.Remove()
+		}
+
+		duration := time.Since(startTime)
+
+		fyne.Do(func() {
+			statusLabel.SetText(fmt.Sprintf("Done in %s. Success: %d (%d from cache, %d too little saved, %d sanitized), Skipped: %d, Signed: %d skipped, %d compressed, Failures: %d", duration.Round(time.Millisecond), successes, cached, notShrunk, sanitized, skipped, signedSkipped, signedCompressed, failures))
+			progressBar.SetValue(1)
+			summary := fmt.Sprintf("Processed %d files in %s.", total, duration.Round(time.Millisecond))
+			if signedSkipped > 0 || signedCompressed > 0 {
+				summary += fmt.Sprintf("\nSigned PDFs: %d skipped, %d compressed with invalidated signatures.", signedSkipped, signedCompressed)
+			}
+			if sanitized > 0 {
+				summary += fmt.Sprintf("\nSanitized %d files, removing %s.", sanitized, removed)
+				appendLog(fmt.Sprintf("Sanitized %d files, removing %s in total.\n", sanitized, removed))
 			}
+			dialog.ShowInformation("Batch Complete", summary+"\nSee log for details.", w)
+		})
+	}
 
-			// 2. Run Pool
-			startTime := time.Now()
-			numWorkers := int(threadSlider.Value)
-			results := worker.RunPool(jobs, numWorkers)
+	compressBtn = widget.NewButton("Compress All", func() {
+		if len(inputFiles) == 0 {
+			dialog.ShowInformation("Info", "Please add files first", w)
+			return
+		}
 
-			completed := 0
-			total := len(jobs)
-			var successes, failures int
-			var unoptimizedFiles []string // Files that got bigger or didn't shrink well (negative ratio?)
-			// Actually User asked "If the file failed to compress tell ... original already optimised... delete?"
+		files, roots := inputFiles, inputRoots
+		inputFiles = []string{}
+		inputRoots = make(map[string]string)
+		updateFileListLabel(fileListLabel, inputFiles)
 
-			for res := range results {
-				completed++
-				progVal := float64(completed) / float64(total)
-
-				var logMsg string
-				if res.Error != nil {
-					failures++
-					logMsg = fmt.Sprintf("[X] %s: Failed - %v\n", filepath.Base(res.Job.InputPath), res.Error)
-				} else {
-					successes++
-					// Calculate Ratio
-					// (1 - Compressed/Original) * 100
-					// If Compressed > Original, Ratio is negative.
-					ratio := CalculateRatio(res.OriginalSize, res.FinalSize)
-
-					logMsg = fmt.Sprintf("[O] %s: Ratio: %.1f%% (%s -> %s)\n",
-						filepath.Base(res.Job.InputPath), ratio,
-						formatBytes(res.OriginalSize), formatBytes(res.FinalSize))
+		beginRun(len(files))
+		numWorkers := int(threadSlider.Value)
+		settings := currentSettings()
 
-					if res.FinalSize >= res.OriginalSize {
-						unoptimizedFiles = append(unoptimizedFiles, res.Job.OutputPath)
-						logMsg += "    -> Larger/Same size. Marked as unoptimized.\n"
-					}
-				}
+		go func() {
+			defer fyne.Do(endRun)
 
+			// 1. Prepare Jobs
+			jobs, err := prepareJobs(files, roots, settings)
+			if err != nil {
 				fyne.Do(func() {
-					progressBar.SetValue(progVal)
-					statusLabel.SetText(fmt.Sprintf("Processed %d/%d", completed, total))
-					logEntry.SetText(logEntry.Text + logMsg)
-					// Scroll to bottom? Fyne currently doesn't have easy auto-scroll entry method
-					// But we can leave it.
+					statusLabel.SetText("Cancelled.")
+					appendLog(fmt.Sprintf("\n%v\n", err))
+					progressBar.SetValue(0)
+					// Put the files back so the settings can be changed
+					inputFiles = append(files, inputFiles...)
+					for file, root := range roots {
+						inputRoots[file] = root
+					}
+					updateFileListLabel(fileListLabel, inputFiles)
+					dialog.ShowError(err, w)
 				})
+				return
 			}
 
-			duration := time.Since(startTime)
-
-			// 3. Post-Process Unoptimized
-			if len(unoptimizedFiles) > 0 {
-				err := zenity.Question(
-					fmt.Sprintf("%d files were already optimized (compression did not reduce size). Delete these output files?", len(unoptimizedFiles)),
-					zenity.Title("Delete Unoptimized Files?"),
-					zenity.OKLabel("Delete"),
-					zenity.CancelLabel("Keep"),
-				)
-
-				if err == nil {
-					// Delete
-					deletedCount := 0
-					for _, f := range unoptimizedFiles {
-						if remErr := os.Remove(f); remErr == nil {
-							deletedCount++
-						}
-					}
-					fyne.Do(func() {
-						logEntry.SetText(logEntry.Text + fmt.Sprintf("\nDeleted %d unoptimized files.", deletedCount))
-					})
-				}
+			// Journal the batch so it can be resumed after a crash
+			jnl, err := journal.Create(jobs)
+			if err != nil {
+				fyne.Do(func() {
+					appendLog(fmt.Sprintf("Warning: batch cannot be resumed: %v\n", err))
+				})
 			}
 
-			fyne.Do(func() {
-				statusLabel.SetText(fmt.Sprintf("Done in %s. Success: %d, Failures: %d", duration.Round(time.Millisecond), successes, failures))
-				progressBar.SetValue(1)
-				dialog.ShowInformation("Batch Complete", fmt.Sprintf("Processed %d files in %s.\nSee log for details.", total, duration.Round(time.Millisecond)), w)
-
-				// Clear file list to reset session
-				inputFiles = []string{}
-				updateFileListLabel(fileListLabel, inputFiles)
-			})
+			runBatch(jobs, jnl, numWorkers)
 		}()
 	})
 	compressBtn.Importance = widget.HighImportance
 
+	// Offer to resume a batch that was interrupted (crash, sleep, quit)
+	resumeLabel := widget.NewLabel("")
+	resumeLabel.Wrapping = fyne.TextWrapWord
+	resumeBtn := widget.NewButton("Resume previous batch", func() {
+		resumeBox.Hide()
+		jnl, err := journal.Load()
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to load previous batch: %w", err), w)
+			return
+		}
+		jobs := jnl.Remaining()
+		for i := range jobs {
+			// The journal keeps options only; apply the current retry,
+			// conflict, shrink and signed PDF settings
+			jobs[i].Retry = retryPolicy(retrySelect.Selected, jobs[i].Options)
+			jobs[i].Conflict = conflictPolicies[conflictSelect.Selected]
+			jobs[i].Shrink = shrinkPolicy(shrinkThreshold.Selected, shrinkAction.Selected)
+			jobs[i].Signature = signaturePolicies[signedSelect.Selected]
+		}
+		skipped := len(jnl.Entries()) - len(jobs)
+		if len(jobs) == 0 {
+			jnl.Remove()
+			dialog.ShowInformation("Resume", "All files of the previous batch are already done.", w)
+			return
+		}
+
+		beginRun(len(jobs))
+		appendLog(fmt.Sprintf("Resuming previous batch: %d done, %d remaining.\n", skipped, len(jobs)))
+		numWorkers := int(threadSlider.Value)
+		go func() {
+			defer fyne.Do(endRun)
+			runBatch(jobs, jnl, numWorkers)
+		}()
+	})
+	discardBtn := widget.NewButton("Discard", func() {
+		resumeBox.Hide()
+		if jnl, err := journal.Load(); err == nil {
+			jnl.Remove()
+		}
+	})
+	resumeBox = container.NewVBox(resumeLabel, container.NewHBox(resumeBtn, discardBtn), widget.NewSeparator())
+	resumeBox.Hide()
+
+	if jnl, err := journal.Load(); err == nil {
+		remaining := len(jnl.Remaining())
+		jnl.Close()
+		if remaining > 0 {
+			resumeLabel.SetText(fmt.Sprintf("A previous batch started %s was interrupted with %d of %d files remaining.",
+				jnl.Created().Format("2006-01-02 15:04"), remaining, len(jnl.Entries())))
+			resumeBox.Show()
+		} else {
+			jnl.Remove()
+		}
+	}
+
 	// 33% width constraint
 	compressBtnLayout := container.NewGridWithColumns(3, layout.NewSpacer(), compressBtn, layout.NewSpacer())
+	pauseLayout := container.NewHBox(pauseBtn, suspendCheck)
 
 	// Main Content
 	content := container.NewVBox(
 		widget.NewLabelWithStyle("Batch File Compression", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
 		widget.NewSeparator(),
+		resumeBox,
 		widget.NewForm(
 			widget.NewFormItem("Files", container.NewVBox(fileListLabel, container.NewHBox(addFilesBtn, addFolderBtn, clearFilesBtn))),
-			widget.NewFormItem("Output Folder", container.NewVBox(outputLabel, selectOutputBtn)),
+			widget.NewFormItem("", filters.content()),
+			widget.NewFormItem("Output Folder", container.NewVBox(outputLabel, selectOutputBtn, mirrorCheck)),
+			widget.NewFormItem("Name Clashes", clashSelect),
+			widget.NewFormItem("Existing Files", conflictSelect),
+			widget.NewFormItem("Signed PDFs", signedSelect),
 			widget.NewFormItem("Quality", qualitySelect),
 			widget.NewFormItem("Filename Suffix", suffixEntry),
+			templateFormItem(templateEntry, templatePreview),
+			widget.NewFormItem("On Failure", retrySelect),
+			widget.NewFormItem("Little Saved", shrinkRow(shrinkThreshold, shrinkAction)),
+			widget.NewFormItem("Keep", container.NewHBox(preserveFiles, preserveDates)),
+			widget.NewFormItem("Metadata", metadataSelect),
+			widget.NewFormItem("Sanitize", sanitize.content()),
+			widget.NewFormItem("Limits", limitsRow(timeoutSelect, memoryLimitSelect, priorityCheck)),
+			widget.NewFormItem("Order", scheduleSelect),
+			widget.NewFormItem("Memory Budget", budgetSelect),
 			widget.NewFormItem("Max Threads", container.NewVBox(threadLabel, threadSlider)),
+			widget.NewFormItem("Performance", reuseCheck),
+			widget.NewFormItem("Cache", container.NewHBox(cacheCheck, forceCheck, widget.NewLabel("Max"), cacheSizeSelect, clearCacheBtn)),
 		),
 		layoutSpacer(),
 		widget.NewSeparator(),
 		layoutSpacer(),
 		progressBar,
-		statusLabel,
+		container.NewBorder(nil, nil, nil, pauseLayout, statusLabel),
+		inProgressLabel,
 		widget.NewLabel("Log:"),
 		logScroll,
 		layoutSpacer(),
@@ -376,6 +844,124 @@ func createBatchFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 	return container.NewPadded(content)
 }
 
+// Scheduling choices
+const (
+	scheduleLargest  = "Largest files first"
+	scheduleFIFO     = "In the order added"
+	scheduleShortest = "Smallest files first"
+	budgetUnlimited  = "Unlimited"
+)
+
+// Choices for outputs that would get the same name
+const (
+	clashNumber = "Number duplicate names"
+	clashStop   = "Stop and list the clashes"
+)
+
+// jobSettings are the widget values jobs are built from, read on the UI
+// goroutine so that the jobs can be prepared in the background
+type jobSettings struct {
+	opts      compression.CompressionOptions
+	outDir    string // "" for a "compressed" folder next to each input
+	template  string
+	suffix    string
+	mirror    bool
+	clashStop bool
+	retry     worker.RetryPolicy
+	conflict  worker.ConflictPolicy
+	shrink    worker.ShrinkPolicy
+	signature worker.SignaturePolicy
+}
+
+// maxClashesListed bounds the clashes named in clashError
+const maxClashesListed = 10
+
+// clashError lists the inputs whose outputs would overwrite each other
+func clashError(files, outputs []string, clashes [][]int) error {
+	msg := fmt.Sprintf("%d output files would be written more than once:", len(clashes))
+	for i, group := range clashes {
+		if i == maxClashesListed {
+			msg += fmt.Sprintf("\n... and %d more", len(clashes)-i)
+			break
+		}
+		msg += "\n" + outputs[group[0]] + " <-"
+		for _, j := range group {
+			msg += "\n    " + files[j]
+		}
+	}
+	return errors.New(msg)
+}
+
+// resolveLateClashes numbers the outputs of jobs added to a running batch
+// that would overwrite an output of the queued jobs or of each other. With
+// stop set it lists the clashes as an error instead.
+func resolveLateClashes(queued, jobs []worker.Job, stop bool) error {
+	files := make([]string, 0, len(queued)+len(jobs))
+	outputs := make([]string, 0, len(queued)+len(jobs))
+	for _, list := range [][]worker.Job{queued, jobs} {
+		for _, job := range list {
+			files = append(files, job.InputPath)
+			outputs = append(outputs, job.OutputPath)
+		}
+	}
+
+	// Only clashes involving an added job count; the queued outputs were
+	// resolved when they were submitted
+	var clashes [][]int
+	for _, group := range spc.Collisions(outputs) {
+		if group[len(group)-1] >= len(queued) {
+			clashes = append(clashes, group)
+		}
+	}
+	if len(clashes) == 0 {
+		return nil
+	}
+	if stop {
+		return clashError(files, outputs, clashes)
+	}
+	outputs = spc.Dedupe(outputs)
+	for i := range jobs {
+		jobs[i].OutputPath = outputs[len(queued)+i]
+	}
+	return nil
+}
+
+func parseSchedule(choice string) worker.Schedule {
+	switch choice {
+	case scheduleFIFO:
+		return worker.ScheduleFIFO
+	case scheduleShortest:
+		return worker.ScheduleShortestFirst
+	}
+	return worker.ScheduleLargestFirst
+}
+
+// formatInProgress describes the files being compressed right now
+func formatInProgress(running map[string]worker.Event) string {
+	if len(running) == 0 {
+		return ""
+	}
+
+	events := make([]worker.Event, 0, len(running))
+	for _, ev := range running {
+		events = append(events, ev)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].WorkerID < events[j].WorkerID })
+
+	msg := "In progress:"
+	for _, ev := range events {
+		msg += fmt.Sprintf("\n  #%d %s", ev.WorkerID, filepath.Base(ev.Job.InputPath))
+		if ev.Type == worker.EventProgress {
+			if ev.Pages > 0 {
+				msg += fmt.Sprintf(" (page %d/%d)", ev.Page, ev.Pages)
+			} else {
+				msg += fmt.Sprintf(" (page %d)", ev.Page)
+			}
+		}
+	}
+	return msg
+}
+
 func updateFileListLabel(l *widget.Label, files []string) {
 	if len(files) == 0 {
 		l.SetText("No files selected")
@@ -392,3 +978,12 @@ func updateFileListLabel(l *widget.Label, files []string) {
 	}
 	l.SetText(msg)
 }
+
+// openCache opens the result cache in the user config dir
+func openCache(maxSize int64) (*cache.Cache, error) {
+	dir, err := cache.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return cache.Open(dir, maxSize)
+}
@@ -2,14 +2,21 @@ package ui
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
-	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/widget"
 	"github.com/ncruces/zenity"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+	"github.com/thelaonerd/simplepdfcompress/internal/naming"
+	"github.com/thelaonerd/simplepdfcompress/internal/worker"
+	"github.com/thelaonerd/simplepdfcompress/pkg/spc"
 )
 
 // Common UI Widgets
@@ -20,13 +27,199 @@ func createQualitySelect() *widget.Select {
 	return sel
 }
 
+// Retry choices for failed files
+const (
+	retryOff          = "Don't retry"
+	retryTransient    = "Retry on transient errors"
+	retryWithFallback = "Retry, then try fallback settings"
+)
+
+func createRetrySelect() *widget.Select {
+	sel := widget.NewSelect([]string{retryOff, retryTransient, retryWithFallback}, nil)
+	sel.SetSelected(retryWithFallback)
+	return sel
+}
+
+// retryPolicy maps a retry choice to the worker policy for opts
+func retryPolicy(choice string, opts compression.CompressionOptions) worker.RetryPolicy {
+	switch choice {
+	case retryTransient:
+		policy := worker.DefaultRetryPolicy(opts)
+		policy.Fallbacks = nil
+		return policy
+	case retryWithFallback:
+		return worker.DefaultRetryPolicy(opts)
+	}
+	return worker.RetryPolicy{}
+}
+
+// Resource limits for each Ghostscript run
+const limitNone = "No limit"
+
+var timeoutChoices = map[string]time.Duration{
+	"1 min":  time.Minute,
+	"5 min":  5 * time.Minute,
+	"15 min": 15 * time.Minute,
+	"1 hour": time.Hour,
+}
+
+func createTimeoutSelect() *widget.Select {
+	sel := widget.NewSelect([]string{limitNone, "1 min", "5 min", "15 min", "1 hour"}, nil)
+	sel.SetSelected(limitNone)
+	return sel
+}
+
+func createMemoryLimitSelect() *widget.Select {
+	sel := widget.NewSelect([]string{limitNone, "512 MB", "1 GB", "2 GB", "4 GB"}, nil)
+	sel.SetSelected(limitNone)
+	return sel
+}
+
+func createPriorityCheck() *widget.Check {
+	return widget.NewCheck("Low CPU/IO priority", nil)
+}
+
+// limitsRow lays out the limit widgets on a single form row
+func limitsRow(timeout, memory *widget.Select, priority *widget.Check) fyne.CanvasObject {
+	return container.NewHBox(
+		widget.NewLabel("Time"), timeout,
+		widget.NewLabel("Memory"), memory,
+		priority,
+	)
+}
+
+// applyLimits copies the limit choices into opts
+func applyLimits(opts *compression.CompressionOptions, timeout, memory string, lowPriority bool) {
+	opts.Timeout = timeoutChoices[timeout]
+	opts.MaxMemory = parseSize(memory)
+	opts.LowPriority = lowPriority
+}
+
+// createPreserveChecks returns the checks for keeping the input's file
+// attributes and its document dates on the output
+func createPreserveChecks() (files, dates *widget.Check) {
+	files = widget.NewCheck("File times, permissions and attributes", nil)
+	dates = widget.NewCheck("Document dates", nil)
+	return files, dates
+}
+
+// applyPreserve copies the preserve choices into opts
+func applyPreserve(opts *compression.CompressionOptions, files, dates bool) {
+	opts.PreserveTimes = files
+	opts.PreserveMode = files
+	opts.PreserveXattrs = files
+	opts.PreserveDates = dates
+}
+
+// Choices for outputs that saved too little
+const (
+	shrinkNone     = "Did not shrink"
+	shrinkKeep     = "Keep output"
+	shrinkDelete   = "Delete output"
+	shrinkOriginal = "Copy original instead"
+)
+
+var shrinkThresholds = map[string]float64{
+	shrinkNone:            0,
+	"Saved less than 1%":  1,
+	"Saved less than 5%":  5,
+	"Saved less than 10%": 10,
+	"Saved less than 20%": 20,
+}
+
+var shrinkActions = map[string]worker.ShrinkAction{
+	shrinkKeep:     worker.ShrinkKeep,
+	shrinkDelete:   worker.ShrinkDelete,
+	shrinkOriginal: worker.ShrinkCopyOriginal,
+}
+
+// createShrinkSelects returns the threshold and action of the policy for
+// outputs that saved too little
+func createShrinkSelects() (*widget.Select, *widget.Select) {
+	threshold := widget.NewSelect([]string{shrinkNone, "Saved less than 1%", "Saved less than 5%", "Saved less than 10%", "Saved less than 20%"}, nil)
+	threshold.SetSelected(shrinkNone)
+	action := widget.NewSelect([]string{shrinkKeep, shrinkDelete, shrinkOriginal}, nil)
+	action.SetSelected(shrinkKeep)
+	return threshold, action
+}
+
+func shrinkRow(threshold, action *widget.Select) fyne.CanvasObject {
+	return container.NewHBox(widget.NewLabel("If"), threshold, widget.NewLabel("then"), action)
+}
+
+func shrinkPolicy(threshold, action string) worker.ShrinkPolicy {
+	return worker.ShrinkPolicy{MinSavings: shrinkThresholds[threshold], Action: shrinkActions[action]}
+}
+
+// describeShrink explains what was done with an output that saved too little
+func describeShrink(policy worker.ShrinkPolicy) string {
+	reason := "did not shrink"
+	if policy.MinSavings > 0 {
+		reason = fmt.Sprintf("saved less than %.0f%%", policy.MinSavings)
+	}
+	return fmt.Sprintf("Output %s, %s", reason, policy.Action)
+}
+
 func createSuffixEntry() *widget.Entry {
 	entry := widget.NewEntry()
-	entry.SetText("_spc_compressed")
-	entry.PlaceHolder = "_spc_compressed"
+	entry.SetText(spc.DefaultSuffix)
+	entry.PlaceHolder = spc.DefaultSuffix
 	return entry
 }
 
+func createTemplateEntry() (*widget.Entry, *widget.Label) {
+	entry := widget.NewEntry()
+	entry.SetText(naming.DefaultTemplate)
+	entry.PlaceHolder = "e.g. {parent}/{name}-small.pdf, {name}_{ratio}pct.pdf"
+	preview := widget.NewLabel("")
+	preview.Wrapping = fyne.TextWrapBreak
+	return entry, preview
+}
+
+// templateFormItem lays out the template entry with its preview and the
+// list of placeholders
+func templateFormItem(entry *widget.Entry, preview *widget.Label) *widget.FormItem {
+	item := widget.NewFormItem("Filename Template", container.NewVBox(entry, preview))
+	item.HintText = "Placeholders: {name} {parent} {suffix} {preset} {date} {time} {size} {pages} {ratio}"
+	return item
+}
+
+// outputTemplate parses the template entry text
+func outputTemplate(text string) (*naming.Template, error) {
+	t, err := naming.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filename template: %w", err)
+	}
+	return t, nil
+}
+
+// templateVars collects the values for an output name template
+func templateVars(input, suffix, quality string, start time.Time) naming.Vars {
+	if suffix == "" {
+		suffix = spc.DefaultSuffix
+	}
+	vars := naming.Vars{Input: input, Suffix: suffix, Preset: quality, Time: start}
+	if info, err := os.Stat(input); err == nil {
+		vars.Size = info.Size()
+	}
+	return vars
+}
+
+// previewOutput shows where the template puts the output of input, with
+// example values for {pages} and {ratio}. input may be empty.
+func previewOutput(text, input, outDir, suffix, quality string) string {
+	t, err := outputTemplate(text)
+	if err != nil {
+		return err.Error()
+	}
+	if input == "" {
+		input = filepath.Join("Documents", "report.pdf")
+	}
+	out := filepath.Join(spc.OutputDir(input, "", outDir), t.Expand(templateVars(input, suffix, quality, time.Now())))
+	out = naming.Resolve(out, naming.Outcome{Pages: 12, OriginalSize: 100, FinalSize: 58})
+	return "Example: " + out
+}
+
 func layoutSpacer() fyne.CanvasObject {
 	return widget.NewLabel("")
 }
@@ -90,25 +283,18 @@ func selectFile(w fyne.Window, title string, onSelected func(fyne.URI)) {
 
 // Logic Helpers
 
-func GenerateOutputPath(inputFile, outputFolderURIPath, suffix string) string {
-	outputDir := ""
-	if outputFolderURIPath != "" {
-		outputDir = outputFolderURIPath
-	} else {
-		outputDir = filepath.Join(filepath.Dir(inputFile), "compressed")
-	}
-
-	if suffix == "" {
-		suffix = "_spc_compressed"
+// parseSize converts "512 MB" / "2 GB" to bytes (0 if not a size, e.g. "Unlimited")
+func parseSize(choice string) int64 {
+	var n int64
+	var unit string
+	if _, err := fmt.Sscanf(choice, "%d %s", &n, &unit); err != nil {
+		return 0
 	}
-
-	baseName := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
-	return filepath.Join(outputDir, fmt.Sprintf("%s%s.pdf", baseName, suffix))
-}
-
-func CalculateRatio(original, final int64) float64 {
-	if original == 0 {
-		return 0.0
+	switch unit {
+	case "GB":
+		return n << 30
+	case "MB":
+		return n << 20
 	}
-	return (1.0 - (float64(final) / float64(original))) * 100.0
+	return 0
 }
@@ -0,0 +1,48 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/worker"
+)
+
+// Choices for outputs that already exist
+const (
+	conflictAsk       = "Ask for each file"
+	conflictOverwrite = "Overwrite"
+	conflictSkip      = "Skip existing files"
+	conflictRename    = "Keep both (number the new file)"
+	conflictOlder     = "Overwrite if older than the input"
+)
+
+var conflictPolicies = map[string]worker.ConflictPolicy{
+	conflictAsk:       worker.ConflictAsk,
+	conflictOverwrite: worker.ConflictOverwrite,
+	conflictSkip:      worker.ConflictSkip,
+	conflictRename:    worker.ConflictRename,
+	conflictOlder:     worker.ConflictOverwriteOlder,
+}
+
+func createConflictSelect() *widget.Select {
+	sel := widget.NewSelect([]string{conflictAsk, conflictOverwrite, conflictSkip, conflictRename, conflictOlder}, nil)
+	sel.SetSelected(conflictAsk)
+	return sel
+}
+
+// newConflictAsker returns an AskFunc that asks in a dialog whether to
+// overwrite an existing output, skip the file or keep both
+func newConflictAsker(w fyne.Window) worker.AskFunc {
+	ask := newBatchPrompt(w, "File Exists",
+		choice[worker.ConflictPolicy]{"Overwrite", worker.ConflictOverwrite},
+		choice[worker.ConflictPolicy]{"Skip", worker.ConflictSkip},
+		choice[worker.ConflictPolicy]{"Keep Both", worker.ConflictRename},
+	)
+	return func(ctx context.Context, job worker.Job, existing string) worker.ConflictPolicy {
+		return ask(ctx, fmt.Sprintf("%s already exists.\nReplace it with the compressed %s?", existing, filepath.Base(job.InputPath)))
+	}
+}
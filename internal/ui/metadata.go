@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+)
+
+// Choices for the input's metadata
+const (
+	metadataDefault  = "Ghostscript default"
+	metadataPreserve = "Preserve all"
+	metadataStrip    = "Strip all (info, XMP, document ID)"
+)
+
+var metadataModes = map[string]compression.MetadataMode{
+	metadataDefault:  compression.MetadataDefault,
+	metadataPreserve: compression.MetadataPreserve,
+	metadataStrip:    compression.MetadataStrip,
+}
+
+func createMetadataSelect() *widget.Select {
+	sel := widget.NewSelect([]string{metadataDefault, metadataPreserve, metadataStrip}, nil)
+	sel.SetSelected(metadataDefault)
+	return sel
+}
+
+// metadataEditor is the single file panel for the metadata mode and the
+// document info entries to set. Empty entries are left as they are.
+type metadataEditor struct {
+	mode     *widget.Select
+	title    *widget.Entry
+	author   *widget.Entry
+	subject  *widget.Entry
+	keywords *widget.Entry
+}
+
+func newMetadataEditor() *metadataEditor {
+	entry := func() *widget.Entry {
+		e := widget.NewEntry()
+		e.SetPlaceHolder("Unchanged")
+		return e
+	}
+	return &metadataEditor{
+		mode:     createMetadataSelect(),
+		title:    entry(),
+		author:   entry(),
+		subject:  entry(),
+		keywords: entry(),
+	}
+}
+
+// content lays out the editor, with the entries folded away until needed
+func (e *metadataEditor) content() fyne.CanvasObject {
+	fields := widget.NewForm(
+		widget.NewFormItem("Title", e.title),
+		widget.NewFormItem("Author", e.author),
+		widget.NewFormItem("Subject", e.subject),
+		widget.NewFormItem("Keywords", e.keywords),
+	)
+	return container.NewVBox(
+		e.mode,
+		widget.NewAccordion(widget.NewAccordionItem("Edit document info", fields)),
+	)
+}
+
+// apply copies the editor's choices into opts
+func (e *metadataEditor) apply(opts *compression.CompressionOptions) {
+	opts.Metadata = metadataModes[e.mode.Selected]
+	opts.Title = e.title.Text
+	opts.Author = e.author.Text
+	opts.Subject = e.subject.Text
+	opts.Keywords = e.keywords.Text
+}
+
+func (e *metadataEditor) setEnabled(on bool) {
+	for _, w := range []fyne.Disableable{e.mode, e.title, e.author, e.subject, e.keywords} {
+		if on {
+			w.Enable()
+		} else {
+			w.Disable()
+		}
+	}
+}
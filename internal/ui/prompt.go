@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"context"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// choice is a button of a batch prompt and the answer it gives
+type choice[T any] struct {
+	label  string
+	answer T
+}
+
+// newBatchPrompt returns a function that asks a question about one file
+// of a batch in a dialog with a button per choice. With "Apply to all"
+// ticked the answer is reused for the rest of the batch. When ctx is done
+// before an answer comes, the dialog is dismissed and the zero T returned.
+func newBatchPrompt[T any](w fyne.Window, title string, choices ...choice[T]) func(ctx context.Context, message string) T {
+	var mu sync.Mutex
+	var remembered *T
+
+	return func(ctx context.Context, message string) T {
+		mu.Lock()
+		if remembered != nil {
+			answer := *remembered
+			mu.Unlock()
+			return answer
+		}
+		mu.Unlock()
+
+		answer := make(chan T, 1)
+		shown := make(chan *dialog.CustomDialog, 1)
+		fyne.Do(func() {
+			msg := widget.NewLabel(message)
+			msg.Wrapping = fyne.TextWrapWord
+			applyAll := widget.NewCheck("Apply to all remaining files", nil)
+
+			var d *dialog.CustomDialog
+			buttons := container.NewHBox()
+			for _, c := range choices {
+				buttons.Add(widget.NewButton(c.label, func() {
+					if applyAll.Checked {
+						mu.Lock()
+						remembered = &c.answer
+						mu.Unlock()
+					}
+					d.Hide()
+					answer <- c.answer
+				}))
+			}
+			d = dialog.NewCustomWithoutButtons(title, container.NewVBox(msg, applyAll, buttons), w)
+			d.Resize(fyne.NewSize(500, 0))
+			d.Show()
+			shown <- d
+		})
+
+		select {
+		case a := <-answer:
+			return a
+		case <-ctx.Done():
+			// Runs after the dialog was shown, fyne.Do keeps the order
+			fyne.Do(func() {
+				(<-shown).Hide()
+			})
+			var zero T
+			return zero
+		}
+	}
+}
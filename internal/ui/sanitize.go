@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+)
+
+// Choices for form fields when sanitizing
+const (
+	formsKeep    = "Keep forms"
+	formsRemove  = "Remove form fields"
+	formsFlatten = "Flatten form fields"
+)
+
+var formActions = map[string]compression.FormAction{
+	formsKeep:    compression.FormsKeep,
+	formsRemove:  compression.FormsRemove,
+	formsFlatten: compression.FormsFlatten,
+}
+
+// sanitizeControls selects what to remove from files from outside
+type sanitizeControls struct {
+	javaScript  *widget.Check
+	attachments *widget.Check
+	annotations *widget.Check
+	thumbnails  *widget.Check
+	forms       *widget.Select
+}
+
+func newSanitizeControls() *sanitizeControls {
+	forms := widget.NewSelect([]string{formsKeep, formsRemove, formsFlatten}, nil)
+	forms.SetSelected(formsKeep)
+	return &sanitizeControls{
+		javaScript:  widget.NewCheck("Document scripts and open actions", nil),
+		attachments: widget.NewCheck("Attachments", nil),
+		annotations: widget.NewCheck("Annotations", nil),
+		thumbnails:  widget.NewCheck("Thumbnails", nil),
+		forms:       forms,
+	}
+}
+
+func (s *sanitizeControls) content() fyne.CanvasObject {
+	return container.NewVBox(
+		container.NewHBox(widget.NewLabel("Remove"), s.javaScript, s.attachments, s.annotations, s.thumbnails),
+		s.forms,
+	)
+}
+
+// apply copies the choices into opts
+func (s *sanitizeControls) apply(opts *compression.CompressionOptions) {
+	opts.Sanitize = compression.Sanitize{
+		JavaScript:    s.javaScript.Checked,
+		EmbeddedFiles: s.attachments.Checked,
+		Annotations:   s.annotations.Checked,
+		Thumbnails:    s.thumbnails.Checked,
+		Forms:         formActions[s.forms.Selected],
+	}
+}
+
+func (s *sanitizeControls) setEnabled(on bool) {
+	for _, w := range []fyne.Disableable{s.javaScript, s.attachments, s.annotations, s.thumbnails, s.forms} {
+		if on {
+			w.Enable()
+		} else {
+			w.Disable()
+		}
+	}
+}
+
+// describeRemoved summarizes what sanitizing with opts took out of a file
+// and what it could not, such as scripts of links and fields that were kept
+func describeRemoved(removed, left compression.ActiveContent, opts compression.Sanitize) string {
+	note := "Sanitized: removed " + removed.String()
+	if opts.Forms == compression.FormsFlatten && removed.FormFields > 0 {
+		fields := compression.ActiveContent{FormFields: removed.FormFields}
+		removed.FormFields = 0
+		if removed.IsZero() {
+			note = "Sanitized: flattened " + fields.String()
+		} else {
+			note = "Sanitized: removed " + removed.String() + "; flattened " + fields.String()
+		}
+	}
+	if !left.IsZero() {
+		note += "; still contains " + left.String()
+		if left.JavaScript > 0 {
+			note += " (scripts of links and form fields are only removed with the annotations and forms)"
+		}
+	}
+	return note
+}
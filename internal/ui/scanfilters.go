@@ -0,0 +1,196 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/scan"
+)
+
+// scanFilters holds the folder scan settings of the batch tab
+type scanFilters struct {
+	include     *widget.Entry
+	exclude     *widget.Entry
+	depth       *widget.Select
+	skipHidden  *widget.Check
+	skipOutputs *widget.Check
+	minSize     *widget.Entry
+	maxSize     *widget.Entry
+	since       *widget.Entry
+	followLinks *widget.Check
+	detect      *widget.Check
+}
+
+const depthUnlimited = "Unlimited"
+
+func newScanFilters() *scanFilters {
+	f := &scanFilters{
+		include:     widget.NewEntry(),
+		exclude:     widget.NewEntry(),
+		depth:       widget.NewSelect([]string{depthUnlimited, "1", "2", "3", "5", "10"}, nil),
+		skipHidden:  widget.NewCheck("Skip hidden files and folders", nil),
+		skipOutputs: widget.NewCheck("Skip output folders", nil),
+		minSize:     widget.NewEntry(),
+		maxSize:     widget.NewEntry(),
+		since:       widget.NewEntry(),
+		followLinks: widget.NewCheck("Follow symbolic links", nil),
+		detect:      widget.NewCheck("Recognise PDFs by content, not extension", nil),
+	}
+	f.include.PlaceHolder = "All PDFs, or e.g. invoice*, reports/*"
+	f.detect.SetChecked(true)
+	f.exclude.PlaceHolder = "e.g. *draft*, archive/*"
+	f.depth.SetSelected(depthUnlimited)
+	f.skipHidden.SetChecked(true)
+	f.skipOutputs.SetChecked(true)
+	f.minSize.PlaceHolder = "MB"
+	f.maxSize.PlaceHolder = "MB"
+	f.since.PlaceHolder = "YYYY-MM-DD"
+	return f
+}
+
+// content lays the filters out in a collapsed accordion
+func (f *scanFilters) content() fyne.CanvasObject {
+	form := widget.NewForm(
+		widget.NewFormItem("Include", f.include),
+		widget.NewFormItem("Exclude", f.exclude),
+		widget.NewFormItem("Max Depth", f.depth),
+		widget.NewFormItem("Size (MB)", container.NewGridWithColumns(4,
+			widget.NewLabel("Min"), f.minSize, widget.NewLabel("Max"), f.maxSize)),
+		widget.NewFormItem("Modified Since", f.since),
+		widget.NewFormItem("", container.NewVBox(f.detect, f.skipHidden, f.skipOutputs, f.followLinks)),
+	)
+	return widget.NewAccordion(widget.NewAccordionItem("Folder Scan Filters", form))
+}
+
+// options converts the filters to scan options. suffix and outputDir
+// identify this app's outputs. It must be called on the UI goroutine.
+func (f *scanFilters) options(suffix, outputDir string) (scan.Options, error) {
+	opts := scan.Options{
+		Include:        scan.SplitPatterns(f.include.Text),
+		Exclude:        scan.SplitPatterns(f.exclude.Text),
+		DetectContent:  f.detect.Checked,
+		SkipHidden:     f.skipHidden.Checked,
+		SkipOutputDirs: f.skipOutputs.Checked,
+		FollowSymlinks: f.followLinks.Checked,
+		Suffix:         suffix,
+		SkipOwnOutputs: true,
+	}
+	if outputDir != "" {
+		opts.OutputDirs = []string{outputDir}
+	}
+	if f.depth.Selected != depthUnlimited {
+		opts.MaxDepth, _ = strconv.Atoi(f.depth.Selected)
+	}
+
+	var err error
+	if opts.MinSize, err = parseMegabytes(f.minSize.Text); err != nil {
+		return opts, fmt.Errorf("invalid minimum size: %w", err)
+	}
+	if opts.MaxSize, err = parseMegabytes(f.maxSize.Text); err != nil {
+		return opts, fmt.Errorf("invalid maximum size: %w", err)
+	}
+	if text := strings.TrimSpace(f.since.Text); text != "" {
+		if opts.ModifiedSince, err = time.ParseInLocation("2006-01-02", text, time.Local); err != nil {
+			return opts, fmt.Errorf("invalid date %q, use YYYY-MM-DD", text)
+		}
+	}
+	return opts, nil
+}
+
+// parseMegabytes converts an entry in MB to bytes ("" means 0)
+func parseMegabytes(text string) (int64, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, nil
+	}
+	mb, err := strconv.ParseFloat(text, 64)
+	if err != nil || mb < 0 {
+		return 0, fmt.Errorf("%q is not a size in MB", text)
+	}
+	return int64(mb * (1 << 20)), nil
+}
+
+// runFolderScan scans dir in the background behind a dialog that shows
+// the PDFs found so far and can cancel the scan. onDone runs on the UI
+// goroutine unless the scan was cancelled.
+func runFolderScan(w fyne.Window, dir string, opts scan.Options, onDone func(scan.Result)) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	status := widget.NewLabel("Found 0 PDFs so far")
+	content := container.NewVBox(
+		widget.NewLabel(fmt.Sprintf("Scanning %s", dir)),
+		widget.NewProgressBarInfinite(),
+		status,
+	)
+	d := dialog.NewCustom("Scanning Folder", "Cancel", content, w)
+	d.SetOnClosed(cancel)
+	d.Show()
+
+	go func() {
+		s := scan.Scanner{
+			Options: opts,
+			OnProgress: func(found, dirs int) {
+				fyne.Do(func() {
+					status.SetText(fmt.Sprintf("Found %d PDFs so far (%d folders read)", found, dirs))
+				})
+			},
+		}
+		res, err := s.Scan(ctx, dir)
+		fyne.Do(func() {
+			if ctx.Err() != nil {
+				return // cancelled by the user
+			}
+			d.Hide()
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			onDone(res)
+		})
+	}()
+}
+
+// showScanPreview lists the files a folder scan matched and calls onAdd
+// if the user confirms
+func showScanPreview(w fyne.Window, dir string, res scan.Result, onAdd func()) {
+	summary := fmt.Sprintf("%d PDF files match", len(res.Files))
+	if res.Filtered > 0 {
+		summary += fmt.Sprintf(", %d filtered out", res.Filtered)
+	}
+	if res.Skipped > 0 {
+		summary += fmt.Sprintf(", %d already compressed by this app", res.Skipped)
+	}
+	if len(res.Unreadable) > 0 {
+		summary += fmt.Sprintf(", %d could not be read (see log)", len(res.Unreadable))
+	}
+
+	list := widget.NewList(
+		func() int { return len(res.Files) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			rel, err := filepath.Rel(dir, res.Files[i])
+			if err != nil {
+				rel = res.Files[i]
+			}
+			o.(*widget.Label).SetText(rel)
+		},
+	)
+	content := container.NewBorder(widget.NewLabel(summary), nil, nil, nil, list)
+
+	d := dialog.NewCustomConfirm("Add Folder", fmt.Sprintf("Add %d Files", len(res.Files)), "Cancel", content, func(ok bool) {
+		if ok {
+			onAdd()
+		}
+	}, w)
+	d.Resize(fyne.NewSize(600, 450))
+	d.Show()
+}
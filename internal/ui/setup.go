@@ -2,11 +2,12 @@ package ui
 
 import (
 	"fmt"
-	"simplepdfcompress/internal/system"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/widget"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/system"
 )
 
 // Setup initializes the application UI based on system checks
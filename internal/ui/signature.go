@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/worker"
+)
+
+// Choices for digitally signed inputs
+const (
+	signedSkip     = "Skip (keep signatures valid)"
+	signedAsk      = "Ask for each file"
+	signedCompress = "Compress anyway (invalidates signatures)"
+)
+
+var signaturePolicies = map[string]worker.SignaturePolicy{
+	signedSkip:     worker.SignatureSkip,
+	signedAsk:      worker.SignatureAsk,
+	signedCompress: worker.SignatureCompress,
+}
+
+func createSignedSelect() *widget.Select {
+	sel := widget.NewSelect([]string{signedSkip, signedAsk, signedCompress}, nil)
+	sel.SetSelected(signedSkip)
+	return sel
+}
+
+// newSignedConfirmer returns a ConfirmSignedFunc that asks in a dialog
+// whether to compress a signed file
+func newSignedConfirmer(w fyne.Window) worker.ConfirmSignedFunc {
+	confirm := newBatchPrompt(w, "Signed PDF",
+		choice[bool]{"Skip", false},
+		choice[bool]{"Compress Anyway", true},
+	)
+	return func(ctx context.Context, job worker.Job) bool {
+		return confirm(ctx, fmt.Sprintf("%s is digitally signed.\nCompressing it invalidates the signature. Compress it anyway?", filepath.Base(job.InputPath)))
+	}
+}
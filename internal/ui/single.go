@@ -1,10 +1,12 @@
 package ui
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -13,7 +15,11 @@ import (
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
 
-	"simplepdfcompress/internal/compression"
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+	"github.com/thelaonerd/simplepdfcompress/internal/fileops"
+	"github.com/thelaonerd/simplepdfcompress/internal/naming"
+	"github.com/thelaonerd/simplepdfcompress/internal/worker"
+	"github.com/thelaonerd/simplepdfcompress/pkg/spc"
 
 	"github.com/ncruces/zenity"
 )
@@ -30,6 +36,26 @@ func createSingleFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 	outputLabel.Truncation = fyne.TextTruncateEllipsis
 
 	qualitySelect := createQualitySelect()
+	timeoutSelect := createTimeoutSelect()
+	memoryLimitSelect := createMemoryLimitSelect()
+	priorityCheck := createPriorityCheck()
+	preserveFiles, preserveDates := createPreserveChecks()
+	metadata := newMetadataEditor()
+	sanitize := newSanitizeControls()
+	shrinkThreshold, shrinkAction := createShrinkSelects()
+
+	// Large files can be split into page ranges compressed on all cores
+	splitCheck := widget.NewCheck("Use all CPU cores for large files", nil)
+	splitThreshold := widget.NewSelect([]string{"10 MB", "25 MB", "50 MB", "100 MB", "250 MB"}, nil)
+	splitThreshold.SetSelected("50 MB")
+	splitThreshold.Disable()
+	splitCheck.OnChanged = func(on bool) {
+		if on {
+			splitThreshold.Enable()
+		} else {
+			splitThreshold.Disable()
+		}
+	}
 
 	progressBar := widget.NewProgressBar()
 	progressBar.Hide()
@@ -42,12 +68,30 @@ func createSingleFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 	logScroll := container.NewVScroll(logEntry)
 	logScroll.SetMinSize(fyne.NewSize(0, 150))
 
+	suffixEntry := createSuffixEntry()
+	templateEntry, templatePreview := createTemplateEntry()
+	refreshPreview := func() {
+		input, outDir := "", ""
+		if selectedFileURI != nil {
+			input = selectedFileURI.Path()
+		}
+		if outputFolderURI != nil {
+			outDir = outputFolderURI.Path()
+		}
+		templatePreview.SetText(previewOutput(templateEntry.Text, input, outDir, suffixEntry.Text, qualitySelect.Selected))
+	}
+	templateEntry.OnChanged = func(string) { refreshPreview() }
+	suffixEntry.OnChanged = func(string) { refreshPreview() }
+	qualitySelect.OnChanged = func(string) { refreshPreview() }
+	refreshPreview()
+
 	// Buttons
 	selectFileBtn := widget.NewButton("Select PDF File", func() {
 		selectFile(w, "Select PDF File", func(uri fyne.URI) {
 			selectedFileURI = uri
 			fileLabel.SetText(uri.Path())
 			logEntry.SetText("") // Clear log on new file
+			refreshPreview()
 		})
 	})
 
@@ -55,24 +99,40 @@ func createSingleFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 		selectFolder(w, "Select Output Folder", func(uri fyne.URI) {
 			outputFolderURI = uri
 			outputLabel.SetText(uri.Path())
+			refreshPreview()
 		})
 	})
 
-	suffixEntry := createSuffixEntry()
-
 	var compressBtn *widget.Button
 	compressBtn = widget.NewButton("Compress", func() {
 		if selectedFileURI == nil {
 			dialog.ShowError(errors.New("please select a file first"), w)
 			return
 		}
+		tmpl, err := outputTemplate(templateEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
 
 		// Disable interactions
 		compressBtn.Disable()
 		selectFileBtn.Disable() // Good practice to disable inputs too
 		selectOutputBtn.Disable()
 		qualitySelect.Disable()
+		timeoutSelect.Disable()
+		memoryLimitSelect.Disable()
+		priorityCheck.Disable()
+		preserveFiles.Disable()
+		preserveDates.Disable()
+		metadata.setEnabled(false)
+		sanitize.setEnabled(false)
+		shrinkThreshold.Disable()
+		shrinkAction.Disable()
 		suffixEntry.Disable()
+		templateEntry.Disable()
+		splitCheck.Disable()
+		splitThreshold.Disable()
 		onStart()
 
 		progressBar.Show()
@@ -87,7 +147,21 @@ func createSingleFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 				selectFileBtn.Enable()
 				selectOutputBtn.Enable()
 				qualitySelect.Enable()
+				timeoutSelect.Enable()
+				memoryLimitSelect.Enable()
+				priorityCheck.Enable()
+				preserveFiles.Enable()
+				preserveDates.Enable()
+				metadata.setEnabled(true)
+				sanitize.setEnabled(true)
+				shrinkThreshold.Enable()
+				shrinkAction.Enable()
 				suffixEntry.Enable()
+				templateEntry.Enable()
+				splitCheck.Enable()
+				if splitCheck.Checked {
+					splitThreshold.Enable()
+				}
 				onEnd()
 			})
 
@@ -98,7 +172,8 @@ func createSingleFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 			if outputFolderURI != nil {
 				outDirPath = outputFolderURI.Path()
 			}
-			outputFile := GenerateOutputPath(inputFile, outDirPath, suffixEntry.Text)
+			vars := templateVars(inputFile, suffixEntry.Text, qualitySelect.Selected, time.Now())
+			outputFile := filepath.Join(spc.OutputDir(inputFile, "", outDirPath), tmpl.Expand(vars))
 
 			logEntryAppend := func(s string) {
 				fyne.Do(func() {
@@ -106,11 +181,30 @@ func createSingleFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 				})
 			}
 
+			// Signed inputs lose their signature, compress only if confirmed
+			if signed, _ := compression.IsSigned(inputFile); signed {
+				err := zenity.Question(
+					fmt.Sprintf("%s is digitally signed.\nCompressing it invalidates the signature. Compress it anyway?", filepath.Base(inputFile)),
+					zenity.Title("Signed PDF"),
+					zenity.OKLabel("Compress Anyway"),
+					zenity.CancelLabel("Cancel"),
+				)
+				if err != nil {
+					fyne.Do(func() {
+						statusLabel.SetText("Cancelled: the file is digitally signed.")
+						progressBar.SetValue(0)
+						logEntry.SetText(logEntry.Text + "Cancelled: File is digitally signed and user chose not to compress it.\n")
+					})
+					return
+				}
+				logEntryAppend("Compressing a signed file, the signature will not be valid in the output.\n")
+			}
+
 			// Overwrite Check
 			if _, err := os.Stat(outputFile); err == nil {
 				// File exists
 				err := zenity.Question(
-					fmt.Sprintf("File already exists:\n%s\nOutput will be overwritten. Continue?", filepath.Base(outputFile)),
+					fmt.Sprintf("File already exists:\n%s\nIt will be moved to the trash and replaced. Continue?", filepath.Base(outputFile)),
 					zenity.Title("Overwrite Confirmation"),
 					zenity.OKLabel("Overwrite"),
 					zenity.CancelLabel("Cancel"),
@@ -124,15 +218,41 @@ func createSingleFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 					})
 					return
 				}
+				if trashed, err := fileops.Remove(outputFile); err != nil {
+					logEntryAppend(fmt.Sprintf("Could not remove existing output: %v\n", err))
+				} else if !trashed {
+					logEntryAppend("No trash available, existing output deleted.\n")
+				}
 			}
 
 			// 2. Compress
 			startTime := time.Now()
+			inputInfo, _ := os.Stat(inputFile)
 			opts := compression.CompressionOptions{
 				Quality: qualitySelect.Selected,
+				Stamp:   true,
+			}
+			applyLimits(&opts, timeoutSelect.Selected, memoryLimitSelect.Selected, priorityCheck.Checked)
+			applyPreserve(&opts, preserveFiles.Checked, preserveDates.Checked)
+			metadata.apply(&opts)
+			sanitize.apply(&opts)
+
+			var initial, final int64
+			var err error
+			if splitCheck.Checked {
+				split := worker.SplitOptions{
+					Threshold: parseSize(splitThreshold.Selected),
+					Workers:   runtime.NumCPU(),
+				}
+				initial, final, err = worker.CompressSplit(context.Background(), inputFile, outputFile, opts, split, func(done, total int) {
+					fyne.Do(func() {
+						progressBar.SetValue(float64(done) / float64(total))
+						statusLabel.SetText(fmt.Sprintf("Compressing... (%d/%d parts)", done, total))
+					})
+				})
+			} else {
+				initial, final, err = compression.CompressPDF(inputFile, outputFile, opts)
 			}
-
-			initial, final, err := compression.CompressPDF(inputFile, outputFile, opts)
 
 			if err != nil {
 				fyne.Do(func() {
@@ -144,11 +264,44 @@ func createSingleFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 				return
 			}
 
+			// Fill in {pages} and {ratio} now that they are known
+			if naming.Pending(outputFile) {
+				outcome := naming.Outcome{OriginalSize: initial, FinalSize: final}
+				if naming.NeedsPages(outputFile) {
+					outcome.Pages, _ = compression.PageCount(context.Background(), outputFile)
+				}
+				named := naming.Resolve(outputFile, outcome)
+				if _, err := os.Stat(named); err == nil {
+					// Only the resolved name can be checked, so ask now
+					err := zenity.Question(
+						fmt.Sprintf("File already exists:\n%s\nReplace it (it will be moved to the trash) or keep both?", filepath.Base(named)),
+						zenity.Title("Overwrite Confirmation"),
+						zenity.OKLabel("Replace"),
+						zenity.CancelLabel("Keep Both"),
+					)
+					if err == nil {
+						if trashed, err := fileops.Remove(named); err != nil {
+							logEntryAppend(fmt.Sprintf("Could not remove existing output: %v\n", err))
+						} else if !trashed {
+							logEntryAppend("No trash available, existing output deleted.\n")
+						}
+					} else {
+						named = freeNumbered(named)
+						logEntryAppend(fmt.Sprintf("Output existed, saving as %s\n", filepath.Base(named)))
+					}
+				}
+				if err := os.Rename(outputFile, named); err != nil {
+					logEntryAppend(fmt.Sprintf("Failed to rename output: %v\n", err))
+				} else {
+					outputFile = named
+				}
+			}
+
 			duration := time.Since(startTime)
 
 			// 3. Ratio & Unoptimized Logic
 			// 3. Ratio & Unoptimized Logic
-			ratio := CalculateRatio(initial, final)
+			ratio := spc.Ratio(initial, final)
 
 			// Msg for success
 			msg := fmt.Sprintf("Success! (Time: %s)\nRatio: %.1f%%\n\nOriginal: %s\nCompressed: %s",
@@ -157,33 +310,39 @@ func createSingleFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 			logEntryAppend(fmt.Sprintf("Success! Ratio: %.1f%% (%s -> %s) in %s\n",
 				ratio, formatBytes(initial), formatBytes(final), duration.Round(time.Millisecond)))
 
-			// Unoptimized check
-			if final >= initial {
-				msg += "\n\nWarning: File did not shrink (already optimized)."
-				logEntryAppend("Warning: File did not shrink.\n")
-
-				err := zenity.Question(
-					"Compression did not reduce file size (it is larger or same size).\nDelete the new output file?",
-					zenity.Title("Unoptimized Result"),
-					zenity.OKLabel("Delete"),
-					zenity.CancelLabel("Keep"),
-				)
+			if opts.Sanitize.Enabled() {
+				removed, left, _ := compression.RemovedContent(context.Background(), inputFile, outputFile, opts.Sanitize)
+				note := describeRemoved(removed, left, opts.Sanitize)
+				msg += "\n\n" + note + "."
+				logEntryAppend(note + "\n")
+			}
 
-				if err == nil {
-					// Delete
-					if remErr := os.Remove(outputFile); remErr == nil {
-						fyne.Do(func() {
-							dialog.ShowInformation("Deleted", "Unoptimized output file was deleted.", w)
-							statusLabel.SetText("Cancelled (Unoptimized deleted).")
-							progressBar.SetValue(0)
-							logEntry.SetText(logEntry.Text + "Deleted unoptimized output file.\n")
-						})
-						return
-					} else {
-						msg += "\n(Failed to delete unoptimized file)"
-						logEntryAppend("Failed to delete unoptimized file.\n")
+			// Outputs that saved too little
+			policy := shrinkPolicy(shrinkThreshold.Selected, shrinkAction.Selected)
+			if opts.Sanitize.Enabled() && policy.Action == worker.ShrinkCopyOriginal {
+				policy.Action = worker.ShrinkKeep // the original is not sanitized
+			}
+			notShrunk, _, shrinkErr := worker.ApplyShrinkPolicy(policy, inputFile, outputFile, initial, final)
+			if shrinkErr != nil {
+				msg += "\n\n" + shrinkErr.Error()
+				logEntryAppend(shrinkErr.Error() + "\n")
+			} else if notShrunk {
+				if policy.Action == worker.ShrinkCopyOriginal {
+					if err := compression.PreserveAttributes(inputFile, outputFile, inputInfo, opts); err != nil {
+						logEntryAppend(err.Error() + "\n")
 					}
 				}
+				note := describeShrink(policy)
+				msg += "\n\n" + note + "."
+				logEntryAppend(note + "\n")
+				if policy.Action == worker.ShrinkDelete {
+					fyne.Do(func() {
+						dialog.ShowInformation("Compression Complete", msg, w)
+						statusLabel.SetText("Output deleted (too little saved).")
+						progressBar.SetValue(0)
+					})
+					return
+				}
 			}
 
 			fyne.Do(func() {
@@ -209,6 +368,13 @@ func createSingleFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 			widget.NewFormItem("Output Folder", container.NewVBox(outputLabel, selectOutputBtn)),
 			widget.NewFormItem("Quality", qualitySelect),
 			widget.NewFormItem("Filename Suffix", suffixEntry),
+			templateFormItem(templateEntry, templatePreview),
+			widget.NewFormItem("Little Saved", shrinkRow(shrinkThreshold, shrinkAction)),
+			widget.NewFormItem("Keep", container.NewHBox(preserveFiles, preserveDates)),
+			widget.NewFormItem("Metadata", metadata.content()),
+			widget.NewFormItem("Sanitize", sanitize.content()),
+			widget.NewFormItem("Limits", limitsRow(timeoutSelect, memoryLimitSelect, priorityCheck)),
+			widget.NewFormItem("Parallel", container.NewHBox(splitCheck, widget.NewLabel("from"), splitThreshold)),
 		),
 		layoutSpacer(),
 		widget.NewSeparator(),
@@ -223,3 +389,14 @@ func createSingleFileTab(w fyne.Window, onStart, onEnd func()) fyne.CanvasObject
 
 	return container.NewPadded(content)
 }
+
+// freeNumbered returns the first numbered variant of path that does not
+// exist, e.g. "report (2).pdf"
+func freeNumbered(path string) string {
+	for n := 2; ; n++ {
+		candidate := naming.Numbered(path, n)
+		if _, err := os.Stat(candidate); errors.Is(err, os.ErrNotExist) {
+			return candidate
+		}
+	}
+}
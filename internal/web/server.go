@@ -0,0 +1,491 @@
+package web
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+	"github.com/thelaonerd/simplepdfcompress/internal/worker"
+	"github.com/thelaonerd/simplepdfcompress/pkg/spc"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+// How long finished batches are kept before their files are removed
+const (
+	batchTTL      = time.Hour
+	downloadedTTL = 5 * time.Minute // once every output was downloaded
+)
+
+// DefaultMaxUpload is the default cap on the size of one upload request
+const DefaultMaxUpload = 1 << 30 // 1 GB
+
+// Server serves the browser UI and the HTTP API used by it.
+// Uploaded files and their compressed outputs live in a temporary
+// working directory that is removed by Close. All batches share one
+// worker pool, so at most maxWorkers files are compressed at once.
+//
+// The server has no authentication: anyone who can reach it can upload
+// files and download every output. Keep it on a loopback address (see
+// ListenAddr) and reach it through an SSH tunnel.
+type Server struct {
+	workDir    string
+	maxWorkers int
+	maxUpload  int64
+	pool       *worker.Pool
+	stop       chan struct{}
+
+	mu      sync.Mutex
+	batches map[string]*batch
+	owners  map[string]*batch // input path -> batch
+	nextID  int
+}
+
+// batch tracks one upload and the events produced while it is compressed
+type batch struct {
+	id      string
+	dir     string
+	total   int
+	outputs map[string]string // download name -> output path
+
+	mu         sync.Mutex
+	events     []Event
+	completed  int
+	done       bool
+	finished   time.Time
+	downloaded map[string]bool
+	notify     chan struct{} // closed and replaced whenever events change
+}
+
+// Event is sent to the browser over server-sent events
+type Event struct {
+	Type         string  `json:"type"` // "result" or "done"
+	File         string  `json:"file,omitempty"`
+	Completed    int     `json:"completed"`
+	Total        int     `json:"total"`
+	OriginalSize int64   `json:"originalSize"`
+	FinalSize    int64   `json:"finalSize"`
+	Ratio        float64 `json:"ratio"`
+	Error        string  `json:"error,omitempty"`
+	Download     string  `json:"download,omitempty"`
+	Signed       bool    `json:"signed,omitempty"` // skipped as digitally signed
+}
+
+// NewServer creates a server that runs at most maxWorkers compressions at
+// once and rejects uploads larger than maxUpload bytes (0 means
+// DefaultMaxUpload)
+func NewServer(maxWorkers int, maxUpload int64) (*Server, error) {
+	if maxWorkers < 1 {
+		maxWorkers = 1
+	}
+	if maxUpload <= 0 {
+		maxUpload = DefaultMaxUpload
+	}
+	dir, err := os.MkdirTemp("", "simplepdfcompress-web-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create working directory: %w", err)
+	}
+	s := &Server{
+		workDir:    dir,
+		maxWorkers: maxWorkers,
+		maxUpload:  maxUpload,
+		pool:       worker.NewPool(maxWorkers),
+		stop:       make(chan struct{}),
+		batches:    make(map[string]*batch),
+		owners:     make(map[string]*batch),
+	}
+	go s.dispatch()
+	go s.janitor()
+	return s, nil
+}
+
+// Close stops the workers and removes all uploaded and compressed files
+func (s *Server) Close() error {
+	close(s.stop)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.pool.Shutdown(ctx)
+	return os.RemoveAll(s.workDir)
+}
+
+// ListenAddr completes addr for ListenAndServe: a bare port or an
+// address without a host listens on 127.0.0.1 only, so the server is
+// exposed to other machines only if asked for explicitly (e.g. 0.0.0.0:8080)
+func ListenAddr(addr string) string {
+	if _, err := strconv.Atoi(addr); err == nil {
+		addr = ":" + addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// ListenAndServe serves the UI on ListenAddr(addr) until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{Addr: ListenAddr(addr), Handler: s.Handler()}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler for the UI and API
+func (s *Server) Handler() http.Handler {
+	static, _ := fs.Sub(staticFiles, "static")
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServerFS(static))
+	mux.HandleFunc("GET /api/info", s.handleInfo)
+	mux.HandleFunc("POST /api/batches", s.handleCreateBatch)
+	mux.HandleFunc("GET /api/batches/{id}/events", s.handleEvents)
+	mux.HandleFunc("GET /api/batches/{id}/files/{name}", s.handleDownload)
+	return mux
+}
+
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"threads":   s.maxWorkers,
+		"qualities": []string{"default", "screen", "ebook", "printer", "prepress"},
+	})
+}
+
+func (s *Server) handleCreateBatch(w http.ResponseWriter, r *http.Request) {
+	// 1. Parse upload (large files spill to disk, up to maxUpload in all)
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("upload is larger than %d MB", s.maxUpload>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	headers := r.MultipartForm.File["files"]
+	if len(headers) == 0 {
+		http.Error(w, "no files uploaded", http.StatusBadRequest)
+		return
+	}
+
+	quality := r.FormValue("quality")
+	suffix := r.FormValue("suffix")
+	if suffix == "" {
+		suffix = spc.DefaultSuffix
+	}
+	if strings.ContainsAny(suffix, `/\`) {
+		http.Error(w, "invalid suffix", http.StatusBadRequest)
+		return
+	}
+
+	// 2. Store inputs in a fresh batch directory
+	s.mu.Lock()
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.mu.Unlock()
+
+	b := &batch{
+		id:         id,
+		dir:        filepath.Join(s.workDir, id),
+		outputs:    make(map[string]string),
+		downloaded: make(map[string]bool),
+		notify:     make(chan struct{}),
+	}
+	inDir := filepath.Join(b.dir, "in")
+	outDir := filepath.Join(b.dir, "out")
+	if err := os.MkdirAll(inDir, 0755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	opts := compression.CompressionOptions{Quality: quality, Stamp: true}
+	jobs := make([]worker.Job, 0, len(headers))
+	used := make(map[string]bool)
+	for _, h := range headers {
+		name := uniqueName(used, filepath.Base(h.Filename))
+		inPath := filepath.Join(inDir, name)
+		if err := saveUpload(h, inPath); err != nil {
+			os.RemoveAll(b.dir)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		jobs = append(jobs, worker.Job{
+			InputPath:  inPath,
+			OutputPath: spc.OutputPath(inPath, outDir, suffix),
+			Options:    opts,
+		})
+	}
+	b.total = len(jobs)
+
+	// 3. Queue the jobs on the shared pool; dispatch records the results
+	s.mu.Lock()
+	s.batches[id] = b
+	for _, job := range jobs {
+		s.owners[job.InputPath] = b
+	}
+	s.mu.Unlock()
+
+	for _, job := range jobs {
+		if err := s.pool.Submit(job); err != nil {
+			s.mu.Lock()
+			delete(s.owners, job.InputPath)
+			s.mu.Unlock()
+			b.record(worker.Result{Job: job, Error: err})
+		}
+	}
+
+	writeJSON(w, map[string]any{"id": id, "total": b.total})
+}
+
+// dispatch routes finished jobs from the shared pool to their batches
+func (s *Server) dispatch() {
+	for ev := range s.pool.Events() {
+		if !ev.Terminal() {
+			continue
+		}
+		s.mu.Lock()
+		b := s.owners[ev.Job.InputPath]
+		delete(s.owners, ev.Job.InputPath)
+		s.mu.Unlock()
+		if b != nil {
+			b.record(ev.Result)
+		}
+	}
+}
+
+// janitor removes finished batches once they have expired
+func (s *Server) janitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.evictExpired(now)
+		}
+	}
+}
+
+func (s *Server) evictExpired(now time.Time) {
+	s.mu.Lock()
+	var expired []*batch
+	for id, b := range s.batches {
+		if b.expired(now) {
+			delete(s.batches, id)
+			expired = append(expired, b)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, b := range expired {
+		os.RemoveAll(b.dir)
+	}
+}
+
+// record publishes the result of one job, and the final "done" event
+// after the last one
+func (b *batch) record(res worker.Result) {
+	b.mu.Lock()
+	b.completed++
+	completed := b.completed
+	b.mu.Unlock()
+
+	ev := Event{
+		Type:         "result",
+		File:         filepath.Base(res.Job.InputPath),
+		Completed:    completed,
+		Total:        b.total,
+		OriginalSize: res.OriginalSize,
+	}
+	if res.Error != nil {
+		ev.Error = res.Error.Error()
+	} else if res.Signature == worker.SignedSkipped {
+		ev.Signed = true
+		ev.Error = "skipped, the file is digitally signed"
+	} else {
+		name := filepath.Base(res.OutputPath)
+		ev.FinalSize = res.FinalSize
+		ev.Ratio = spc.Ratio(res.OriginalSize, res.FinalSize)
+		ev.Download = fmt.Sprintf("/api/batches/%s/files/%s", b.id, url.PathEscape(name))
+
+		b.mu.Lock()
+		b.outputs[name] = res.OutputPath
+		b.mu.Unlock()
+	}
+	b.publish(ev, false)
+
+	if completed == b.total {
+		b.publish(Event{Type: "done", Completed: completed, Total: b.total}, true)
+	}
+}
+
+func (b *batch) publish(ev Event, done bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, ev)
+	b.done = done
+	if done {
+		b.finished = time.Now()
+	}
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// expired reports whether a finished batch can be removed. Batches whose
+// outputs were all downloaded are kept only briefly.
+func (b *batch) expired(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.done {
+		return false
+	}
+	ttl := batchTTL
+	if len(b.outputs) > 0 && len(b.downloaded) == len(b.outputs) {
+		ttl = downloadedTTL
+	}
+	return now.Sub(b.finished) > ttl
+}
+
+func (s *Server) lookup(id string) *batch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batches[id]
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	b := s.lookup(r.PathValue("id"))
+	if b == nil {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// Replay everything so far, then wait for new events
+	sent := 0
+	for {
+		b.mu.Lock()
+		pending := b.events[sent:]
+		done := b.done
+		notify := b.notify
+		b.mu.Unlock()
+
+		for _, ev := range pending {
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			sent++
+		}
+		flusher.Flush()
+
+		if done {
+			return
+		}
+
+		select {
+		case <-notify:
+		case <-time.After(15 * time.Second):
+			// Keep idle connections (and proxies) alive
+			fmt.Fprint(w, ": ping\n\n")
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	b := s.lookup(r.PathValue("id"))
+	if b == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	name := r.PathValue("name")
+	b.mu.Lock()
+	path, ok := b.outputs[name]
+	if ok {
+		b.downloaded[name] = true
+	}
+	b.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeFile(w, r, path)
+}
+
+// Helpers
+
+func saveUpload(h *multipart.FileHeader, dst string) error {
+	src, err := h.Open()
+	if err != nil {
+		return fmt.Errorf("failed to read upload: %w", err)
+	}
+	defer src.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to store upload: %w", err)
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to store upload: %w", err)
+	}
+	return out.Close()
+}
+
+// uniqueName makes uploaded names unique within a batch so that
+// two files called report.pdf do not overwrite each other.
+func uniqueName(used map[string]bool, name string) string {
+	if name == "." || name == string(filepath.Separator) {
+		name = "upload.pdf"
+	}
+	candidate := name
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListenAddr(t *testing.T) {
+	tests := []struct{ addr, want string }{
+		{"8080", "127.0.0.1:8080"},
+		{":8080", "127.0.0.1:8080"},
+		{"127.0.0.1:8080", "127.0.0.1:8080"},
+		{"0.0.0.0:8080", "0.0.0.0:8080"},
+		{"[::1]:8080", "[::1]:8080"},
+	}
+	for _, tt := range tests {
+		if got := ListenAddr(tt.addr); got != tt.want {
+			t.Errorf("ListenAddr(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestUploadTooLarge(t *testing.T) {
+	s, err := NewServer(1, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("files", "big.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(bytes.Repeat([]byte("x"), 4096))
+	form.Close()
+
+	req := httptest.NewRequest("POST", "/api/batches", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
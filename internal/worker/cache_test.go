@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/cache"
+)
+
+func TestPoolCache(t *testing.T) {
+	// Counts its runs in a file next to itself
+	gs := fakeGhostscript(t, `echo run >> "$(dirname "$0")/runs"`+"\n"+writeOutput)
+	runs := func() int {
+		data, _ := os.ReadFile(filepath.Join(gs, "runs"))
+		return len(data) / len("run\n")
+	}
+	dir := t.TempDir()
+	c, err := cache.Open(filepath.Join(dir, "cache"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	input := filepath.Join(dir, "in.pdf")
+	writeFile(t, input, "%PDF-1.4\n% a larger input\n%%EOF\n")
+	job := Job{InputPath: input, OutputPath: filepath.Join(dir, "out.pdf"), Conflict: ConflictOverwrite}
+
+	tests := []struct {
+		name       string
+		force      bool
+		wantCached bool
+		wantRuns   int
+	}{
+		{"first run compresses", false, false, 1},
+		{"second run is restored", false, true, 1},
+		{"force compresses again", true, false, 2},
+	}
+	for _, tt := range tests {
+		res := runJob(t, job, func(p *Pool) { p.SetCache(c, tt.force) })
+		if res.Error != nil {
+			t.Fatalf("%s: %v", tt.name, res.Error)
+		}
+		if res.Cached != tt.wantCached || runs() != tt.wantRuns {
+			t.Errorf("%s: Cached %v after %d runs, want %v after %d", tt.name, res.Cached, runs(), tt.wantCached, tt.wantRuns)
+		}
+	}
+}
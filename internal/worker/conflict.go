@@ -0,0 +1,173 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/fileops"
+	"github.com/thelaonerd/simplepdfcompress/internal/naming"
+)
+
+// ConflictPolicy decides what happens when a job's output already exists
+type ConflictPolicy int
+
+const (
+	ConflictOverwrite      ConflictPolicy = iota // replace the existing file
+	ConflictSkip                                 // keep the existing file, do not compress
+	ConflictRename                               // write the output under a numbered name
+	ConflictOverwriteOlder                       // replace only files older than the input
+	ConflictAsk                                  // call the pool's AskFunc
+)
+
+func (c ConflictPolicy) String() string {
+	switch c {
+	case ConflictOverwrite:
+		return "overwrite"
+	case ConflictSkip:
+		return "skip"
+	case ConflictRename:
+		return "rename"
+	case ConflictOverwriteOlder:
+		return "overwrite older"
+	case ConflictAsk:
+		return "ask"
+	}
+	return "unknown"
+}
+
+// Resolution records how an existing output was handled
+type Resolution int
+
+const (
+	NoConflict Resolution = iota // the output did not exist
+	Overwrote                    // the existing output was replaced
+	Skipped                      // the existing output was kept and the job not run
+	Renamed                      // the output was written under a numbered name
+)
+
+func (r Resolution) String() string {
+	switch r {
+	case NoConflict:
+		return "none"
+	case Overwrote:
+		return "overwrote"
+	case Skipped:
+		return "skipped"
+	case Renamed:
+		return "renamed"
+	}
+	return "unknown"
+}
+
+// AskFunc is called for jobs with ConflictAsk whose output exists. It
+// returns ConflictOverwrite, ConflictSkip or ConflictRename. Calls are
+// made one at a time, from the worker handling the job. ctx is done when
+// the pool shuts down; the function should then return, and its answer
+// is ignored.
+type AskFunc func(ctx context.Context, job Job, existing string) ConflictPolicy
+
+// SetAskFunc sets the function deciding ConflictAsk jobs. Without one,
+// existing outputs of such jobs are skipped.
+func (p *Pool) SetAskFunc(ask AskFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ask = ask
+}
+
+// resolveConflict applies job's conflict policy to output. It returns the
+// path to write to and how an existing file was handled. Nothing is
+// removed yet: an Overwrote file is only replaced once the new output is
+// complete (see replaceOutput). A Renamed path is reserved by creating it
+// empty, so that concurrent jobs do not pick the same name.
+func (p *Pool) resolveConflict(job Job, output string) (string, Resolution) {
+	existing, err := os.Stat(output)
+	if err != nil {
+		return output, NoConflict
+	}
+
+	policy := job.Conflict
+	if policy == ConflictAsk {
+		policy = p.askUser(job, output)
+	}
+	switch policy {
+	case ConflictOverwrite:
+		return output, Overwrote
+	case ConflictRename:
+		return reserveNumbered(output), Renamed
+	case ConflictOverwriteOlder:
+		if input, err := os.Stat(job.InputPath); err == nil && existing.ModTime().Before(input.ModTime()) {
+			return output, Overwrote
+		}
+	}
+	return output, Skipped
+}
+
+// reserveNumbered creates the first free numbered variant of output
+// empty and returns its path
+func reserveNumbered(output string) string {
+	for n := 2; ; n++ {
+		candidate := naming.Numbered(output, n)
+		f, err := os.OpenFile(candidate, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return candidate
+		}
+		if !os.IsExist(err) {
+			return candidate // let the compression report the problem
+		}
+	}
+}
+
+// scratchOutput creates a temporary file next to output for a job that
+// replaces it, so that the existing file survives a failed job
+func scratchOutput(output string) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(output), ".spc-out-*.pdf")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary output: %w", err)
+	}
+	f.Close()
+	// Temporary files are private; outputs get the usual permissions
+	os.Chmod(f.Name(), 0644)
+	return f.Name(), nil
+}
+
+// replaceOutput moves the complete output at scratch over output. The
+// file it replaces goes to the trash, so it can be restored.
+func replaceOutput(scratch, output string) error {
+	fileops.Remove(output)
+	if err := os.Rename(scratch, output); err != nil {
+		os.Remove(scratch)
+		return fmt.Errorf("failed to replace output file: %w", err)
+	}
+	return nil
+}
+
+// askUser calls the AskFunc, giving up if the pool is shut down meanwhile
+func (p *Pool) askUser(job Job, output string) ConflictPolicy {
+	p.mu.Lock()
+	ask := p.ask
+	p.mu.Unlock()
+	if ask == nil {
+		return ConflictSkip
+	}
+
+	return prompt(p, func(ctx context.Context) ConflictPolicy { return ask(ctx, job, output) }, ConflictSkip)
+}
+
+// prompt runs ask with the pool's context, one prompt of the pool at a
+// time, and returns fallback if the pool is shut down before the answer
+// comes
+func prompt[T any](p *Pool, ask func(ctx context.Context) T, fallback T) T {
+	p.askMu.Lock()
+	defer p.askMu.Unlock()
+	if p.ctx.Err() != nil {
+		return fallback
+	}
+	answer := ask(p.ctx)
+	if p.ctx.Err() != nil {
+		return fallback
+	}
+	return answer
+}
@@ -0,0 +1,142 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/naming"
+)
+
+// newIdlePool returns a pool without workers for calling its methods
+func newIdlePool(t *testing.T) *Pool {
+	t.Helper()
+	p := NewPool(0)
+	t.Cleanup(p.cancel)
+	return p
+}
+
+func TestResolveConflict(t *testing.T) {
+	now := time.Now()
+	older, newer := now.Add(-time.Hour), now.Add(time.Hour)
+	tests := []struct {
+		name      string
+		policy    ConflictPolicy
+		ask       AskFunc
+		modified  time.Time // of the existing output
+		numbered  int       // numbered names that exist already
+		want      Resolution
+		wantPath  string
+		wantEmpty bool // the returned path was reserved
+	}{
+		{name: "overwrite", policy: ConflictOverwrite, want: Overwrote, wantPath: "out.pdf"},
+		{name: "skip", policy: ConflictSkip, want: Skipped, wantPath: "out.pdf"},
+		{name: "rename", policy: ConflictRename, want: Renamed, wantPath: "out (2).pdf", wantEmpty: true},
+		{name: "rename past taken names", policy: ConflictRename, numbered: 2, want: Renamed, wantPath: "out (4).pdf", wantEmpty: true},
+		{name: "overwrite older output", policy: ConflictOverwriteOlder, modified: older, want: Overwrote, wantPath: "out.pdf"},
+		{name: "keep newer output", policy: ConflictOverwriteOlder, modified: newer, want: Skipped, wantPath: "out.pdf"},
+		{name: "ask without AskFunc", policy: ConflictAsk, want: Skipped, wantPath: "out.pdf"},
+		{
+			name:   "ask",
+			policy: ConflictAsk,
+			ask: func(ctx context.Context, job Job, existing string) ConflictPolicy {
+				return ConflictRename
+			},
+			want: Renamed, wantPath: "out (2).pdf", wantEmpty: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "in.pdf")
+			output := filepath.Join(dir, "out.pdf")
+			writeFile(t, input, "input")
+			writeFile(t, output, "existing")
+			if err := os.Chtimes(input, now, now); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.modified.IsZero() {
+				if err := os.Chtimes(output, tt.modified, tt.modified); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for n := 2; n < 2+tt.numbered; n++ {
+				writeFile(t, naming.Numbered(output, n), "taken")
+			}
+
+			p := newIdlePool(t)
+			p.SetAskFunc(tt.ask)
+			path, got := p.resolveConflict(Job{InputPath: input, OutputPath: output, Conflict: tt.policy}, output)
+			if got != tt.want || path != filepath.Join(dir, tt.wantPath) {
+				t.Errorf("resolveConflict = %s, %s, want %s, %s", filepath.Base(path), got, tt.wantPath, tt.want)
+			}
+			if got := readFile(t, output); got != "existing" {
+				t.Errorf("existing output = %q, want it untouched until the job succeeds", got)
+			}
+			if tt.wantEmpty {
+				if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+					t.Errorf("%s was not reserved: %v", path, err)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveConflictNoOutput(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.pdf")
+	p := newIdlePool(t)
+	path, got := p.resolveConflict(Job{OutputPath: output, Conflict: ConflictSkip}, output)
+	if got != NoConflict || path != output {
+		t.Errorf("resolveConflict = %s, %s, want %s, none", path, got, output)
+	}
+}
+
+func TestAskUserAfterShutdown(t *testing.T) {
+	p := newIdlePool(t)
+	asked := false
+	p.SetAskFunc(func(ctx context.Context, job Job, existing string) ConflictPolicy {
+		asked = true
+		return ConflictOverwrite
+	})
+	p.cancel()
+	if got := p.askUser(Job{}, "out.pdf"); got != ConflictSkip || asked {
+		t.Errorf("askUser = %s (asked %v), want skip without asking", got, asked)
+	}
+}
+
+// An answer that comes after the pool was shut down is ignored
+func TestAskUserShutdownWhileAsking(t *testing.T) {
+	p := newIdlePool(t)
+	p.SetAskFunc(func(ctx context.Context, job Job, existing string) ConflictPolicy {
+		p.cancel()
+		<-ctx.Done()
+		return ConflictOverwrite
+	})
+	if got := p.askUser(Job{}, "out.pdf"); got != ConflictSkip {
+		t.Errorf("askUser = %s, want skip", got)
+	}
+}
+
+func TestReplaceOutput(t *testing.T) {
+	isolateTrash(t)
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.pdf")
+	writeFile(t, output, "existing")
+	scratch, err := scratchOutput(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, scratch, "compressed")
+
+	if err := replaceOutput(scratch, output); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, output); got != "compressed" {
+		t.Errorf("output = %q, want %q", got, "compressed")
+	}
+	if _, err := os.Stat(scratch); !os.IsNotExist(err) {
+		t.Errorf("scratch file %s is still there", scratch)
+	}
+}
@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies a step in a job's lifecycle
+type EventType int
+
+const (
+	EventQueued    EventType = iota // job accepted by Submit
+	EventStarted                    // a worker picked the job up
+	EventProgress                   // a page was processed
+	EventRetrying                   // an attempt failed and the job is retried
+	EventFinished                   // job succeeded (Result is set)
+	EventFailed                     // job failed (Result is set)
+	EventCancelled                  // job was dropped or killed by Shutdown
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventQueued:
+		return "queued"
+	case EventStarted:
+		return "started"
+	case EventProgress:
+		return "progress"
+	case EventRetrying:
+		return "retrying"
+	case EventFinished:
+		return "finished"
+	case EventFailed:
+		return "failed"
+	case EventCancelled:
+		return "cancelled"
+	}
+	return "unknown"
+}
+
+// Event reports a change in a job's state
+type Event struct {
+	Type     EventType
+	Job      Job
+	WorkerID int           // worker handling the job (0 while queued)
+	Time     time.Time     // when the event happened
+	Elapsed  time.Duration // time since the job started (0 while queued)
+
+	// Progress
+	Page  int
+	Pages int // 0 if not known yet
+
+	// Retrying: the attempt that failed, and the fallback used next
+	// ("" when the same settings are retried)
+	Attempt  int
+	Error    error
+	Fallback string
+
+	// Finished, Failed and Cancelled
+	Result Result
+}
+
+// Terminal reports whether the event is the last one for its job
+func (e Event) Terminal() bool {
+	return e.Type == EventFinished || e.Type == EventFailed || e.Type == EventCancelled
+}
+
+// eventQueue is an unbounded buffer between the workers and the Events()
+// channel, so a slow consumer never stalls compression.
+type eventQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []Event
+	closed bool
+	out    chan Event
+}
+
+func newEventQueue() *eventQueue {
+	q := &eventQueue{out: make(chan Event)}
+	q.cond = sync.NewCond(&q.mu)
+	go q.dispatch()
+	return q
+}
+
+func (q *eventQueue) push(ev Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.buf = append(q.buf, ev)
+	q.cond.Signal()
+}
+
+// close lets the dispatcher flush the buffer and then close out
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Signal()
+}
+
+func (q *eventQueue) dispatch() {
+	for {
+		q.mu.Lock()
+		for len(q.buf) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.buf) == 0 {
+			q.mu.Unlock()
+			close(q.out)
+			return
+		}
+		batch := q.buf
+		q.buf = nil
+		q.mu.Unlock()
+
+		for _, ev := range batch {
+			q.out <- ev
+		}
+	}
+}
@@ -1,16 +1,31 @@
 package worker
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
 	"sync"
+	"time"
 
-	"simplepdfcompress/internal/compression"
+	"github.com/thelaonerd/simplepdfcompress/internal/cache"
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+	"github.com/thelaonerd/simplepdfcompress/internal/naming"
 )
 
+// ErrPoolClosed is returned by Submit after Close or Shutdown
+var ErrPoolClosed = errors.New("worker pool is closed")
+
 // Job represents a single compression task
 type Job struct {
 	InputPath  string
 	OutputPath string
 	Options    compression.CompressionOptions
+	Retry      RetryPolicy
+	Conflict   ConflictPolicy  // what to do if OutputPath exists
+	Shrink     ShrinkPolicy    // what to do if the output saved too little
+	Signature  SignaturePolicy // what to do if the input is digitally signed
+	Split      SplitOptions    // compress large inputs in page ranges at once
 }
 
 // Result represents the outcome of a compression job
@@ -18,44 +33,728 @@ type Result struct {
 	Job          Job
 	OriginalSize int64
 	FinalSize    int64
+	Duration     time.Duration
 	Error        error
+
+	// Attempts is the number of compression runs, including the last one
+	Attempts int
+	// Fallback names the RetryPolicy fallback that produced the output
+	// ("" if the job's own options were used)
+	Fallback string
+	// Cached is set when the output was taken from the result cache
+	// instead of being compressed (Attempts is 0)
+	Cached bool
+	// OutputPath is where the output was written. It differs from
+	// Job.OutputPath when that names {pages} or {ratio}, which are
+	// filled in once the file is compressed, or when Conflict is Renamed.
+	OutputPath string
+	// Conflict records how an existing output was handled. Skipped jobs
+	// finish without compressing; FinalSize is the existing file's size.
+	Conflict Resolution
+	// NotShrunk is set when the output saved less than Job.Shrink asks
+	// for; Job.Shrink.Action was applied to it. FinalSize is still the
+	// size of the compressed output.
+	NotShrunk bool
+	// Removed counts what sanitizing took out of the file (see
+	// compression.Sanitize)
+	Removed compression.ActiveContent
+	// Left counts what sanitizing was asked to remove but the output
+	// still has, e.g. scripts of links and form fields that were kept
+	Left compression.ActiveContent
+	// Signature records whether the input was signed and, if so, whether
+	// it was compressed. SignedSkipped jobs finish without an output.
+	Signature SignatureHandling
+}
+
+// Pool is a long-lived set of workers that compress jobs as they are submitted.
+// Job lifecycle events are streamed on Events() until the pool is closed and drained.
+type Pool struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []queuedJob
+	size    int          // desired number of workers
+	workers int          // running worker goroutines
+	ids     map[int]bool // worker IDs in use
+	closed  bool
+	drained bool // closed and every worker has exited
+
+	paused    bool
+	suspended bool                     // running processes were sent SIGSTOP
+	running   map[*os.Process]struct{} // Ghostscript processes in flight
+
+	schedule Schedule
+	budget   int64 // max input bytes in flight (0 = unlimited)
+	inFlight int64 // input bytes of running jobs and split parts
+	busy     int   // workers running a job
+	borrowed int   // idle workers lent to the parts of split jobs
+
+	reuse bool // each worker keeps a Ghostscript interpreter alive
+
+	cache *cache.Cache
+	force bool // compress even if the result is cached
+
+	ask           AskFunc
+	confirmSigned ConfirmSignedFunc
+	askMu         sync.Mutex // one question at a time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	events *eventQueue
+	done   chan struct{}
+}
+
+// NewPool starts a pool with numWorkers workers (at least one)
+func NewPool(numWorkers int) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		ids:     make(map[int]bool),
+		ctx:     ctx,
+		cancel:  cancel,
+		events:  newEventQueue(),
+		done:    make(chan struct{}),
+		running: make(map[*os.Process]struct{}),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	p.Resize(numWorkers)
+	return p
+}
+
+// Submit queues a job. It fails with ErrPoolClosed once the pool is closed.
+func (p *Pool) Submit(job Job) error {
+	size := inputSize(job)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+	p.queue = append(p.queue, queuedJob{job: job, size: size})
+	p.events.push(Event{Type: EventQueued, Job: job, Time: time.Now()})
+	p.cond.Signal()
+	return nil
+}
+
+// Events streams lifecycle events for every submitted job. Each job ends
+// with exactly one Finished, Failed or Cancelled event. The channel is
+// closed after Close once every queued job has finished.
+func (p *Pool) Events() <-chan Event {
+	return p.events.out
+}
+
+// Resize changes the number of workers. Extra workers exit after their
+// current job, so shrinking never interrupts a running compression.
+func (p *Pool) Resize(numWorkers int) {
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.size = numWorkers
+	for !p.drained && p.workers < p.size {
+		p.workers++
+		go p.work(p.claimID())
+	}
+	// Wake idle workers so surplus ones can exit
+	p.cond.Broadcast()
+}
+
+// claimID returns the lowest free worker ID, starting at 1
+func (p *Pool) claimID() int {
+	id := 1
+	for p.ids[id] {
+		id++
+	}
+	p.ids[id] = true
+	return id
+}
+
+// Size returns the current target number of workers
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size
+}
+
+// Pending returns the number of queued jobs that have not started yet
+func (p *Pool) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.queue)
+}
+
+// Pause stops dispatching queued jobs. Running jobs keep going unless
+// suspendRunning is set, in which case their Ghostscript processes are
+// stopped as well (Unix only, see compression.CanSuspend).
+func (p *Pool) Pause(suspendRunning bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = true
+	if suspendRunning && compression.CanSuspend() && !p.suspended {
+		p.suspended = true
+		for proc := range p.running {
+			compression.SuspendProcess(proc)
+		}
+	}
+}
+
+// Resume continues dispatching jobs and wakes suspended processes
+func (p *Pool) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resumeLocked()
+}
+
+func (p *Pool) resumeLocked() {
+	p.paused = false
+	if p.suspended {
+		p.suspended = false
+		for proc := range p.running {
+			compression.ResumeProcess(proc)
+		}
+	}
+	p.cond.Broadcast()
+}
+
+// Paused reports whether the pool is paused
+func (p *Pool) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// Close stops accepting jobs. Queued jobs still run; Events() is closed
+// when they are done.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.cond.Broadcast()
+}
+
+// Shutdown closes the pool and waits for queued jobs to finish.
+// If ctx expires first, queued jobs are dropped, running Ghostscript
+// processes are killed and ctx.Err() is returned. Dropped and killed
+// jobs are reported as Cancelled events.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.Close()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		now := time.Now()
+		for _, q := range p.queue {
+			p.events.push(Event{
+				Type:   EventCancelled,
+				Job:    q.job,
+				Time:   now,
+				Result: Result{Job: q.job, Error: ctx.Err()},
+			})
+		}
+		p.queue = nil
+		p.resumeLocked() // let paused workers see the empty queue and exit
+		p.mu.Unlock()
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+// SetReuseProcesses makes each worker compress its jobs in one long-lived
+// Ghostscript interpreter (see compression.Session) instead of starting a
+// process per job. This mostly pays off for many small files; page
+// progress events are not available in this mode.
+func (p *Pool) SetReuseProcesses(on bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reuse = on
+}
+
+// SetCache makes the pool reuse outputs from c for inputs it has seen
+// with the same options, and store new outputs in it. With force set,
+// every job is compressed again and its cache entry refreshed. A nil
+// cache disables caching.
+func (p *Pool) SetCache(c *cache.Cache, force bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cache = c
+	p.force = force
+}
+
+func (p *Pool) cacheSettings() (*cache.Cache, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cache, p.force
+}
+
+func (p *Pool) reusing() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reuse
+}
+
+func (p *Pool) work(id int) {
+	session := compression.NewSession()
+	defer session.Close()
+
+	for {
+		q, ok := p.next(id)
+		if !ok {
+			return
+		}
+		if !p.reusing() {
+			session.Close()
+		}
+		p.events.push(p.run(id, q.job, session))
+		p.release(q.size)
+	}
+}
+
+// run compresses one job, emitting Started, Progress and Retrying
+// events, and returns the terminal event.
+func (p *Pool) run(id int, job Job, session *compression.Session) Event {
+	start := time.Now()
+	p.events.push(Event{Type: EventStarted, Job: job, WorkerID: id, Time: start})
+	// Taken before hashing and compressing read the input
+	inputInfo, _ := os.Stat(job.InputPath)
+	// A copy of the original would bring back what was sanitized away
+	if job.Options.Sanitize.Enabled() && job.Shrink.Action == ShrinkCopyOriginal {
+		job.Shrink.Action = ShrinkKeep
+	}
+
+	// One read of the input serves the signature check, the cache key and
+	// the stamp. Inputs that cannot be read are left to the compression
+	// to report.
+	digest, digestErr := compression.DigestFile(job.InputPath)
+	job.Options.SourceHash = digest.Hash
+
+	// Signed inputs would lose their signature
+	signature := p.checkSignature(job, digest.Signed)
+	if signature == SignedSkipped {
+		end := time.Now()
+		res := Result{Job: job, Duration: end.Sub(start), Signature: SignedSkipped}
+		if inputInfo != nil {
+			res.OriginalSize = inputInfo.Size()
+		}
+		return Event{Type: EventFinished, Job: job, WorkerID: id, Time: end, Elapsed: end.Sub(start), Result: res}
+	}
+
+	// Existing outputs; target is job with the output it is written to.
+	// Names with {pages} or {ratio} are checked once they are known.
+	// Outputs that replace a file are written next to it first.
+	target := job
+	conflict := NoConflict
+	if !naming.Pending(job.OutputPath) {
+		target.OutputPath, conflict = p.resolveConflict(job, job.OutputPath)
+		if conflict == Skipped {
+			return p.skipped(id, job, job.OutputPath, start)
+		}
+		if conflict == Overwrote {
+			scratch, err := scratchOutput(job.OutputPath)
+			if err != nil {
+				return p.failed(id, job, err, start)
+			}
+			target.OutputPath = scratch
+		}
+	}
+	// A scratch file or reserved name is not left behind by a failed job
+	discard := func() {
+		if target.OutputPath != job.OutputPath {
+			os.Remove(target.OutputPath)
+		}
+	}
+
+	// Unchanged inputs compressed before with the same settings
+	c, force := p.cacheSettings()
+	var key cache.Key
+	if digestErr != nil {
+		c = nil
+	}
+	if c != nil {
+		var err error
+		key, err = cache.KeyFor(digest.Hash, job.Options)
+		if err != nil {
+			c = nil
+		} else if !force {
+			if entry, ok, _ := c.Restore(key, target.OutputPath); ok {
+				output, resolution, err := p.finishOutput(job, target.OutputPath, conflict, entry.OriginalSize, entry.OutputSize)
+				if resolution == Skipped {
+					return p.skipped(id, job, output, start)
+				}
+				if resolution != NoConflict {
+					conflict = resolution
+				}
+				notShrunk := false
+				var removed, left compression.ActiveContent
+				if err == nil {
+					removed, left = p.sanitized(job, output)
+					notShrunk, err = p.finishShrink(job, output, inputInfo, entry.OriginalSize, entry.OutputSize)
+				} else {
+					discard()
+				}
+				end := time.Now()
+				ev := Event{
+					Type:     EventFinished,
+					Job:      job,
+					WorkerID: id,
+					Time:     end,
+					Elapsed:  end.Sub(start),
+					Result: Result{
+						Job:          job,
+						OriginalSize: entry.OriginalSize,
+						FinalSize:    entry.OutputSize,
+						Duration:     end.Sub(start),
+						Error:        err,
+						Cached:       true,
+						OutputPath:   output,
+						Conflict:     conflict,
+						NotShrunk:    notShrunk,
+						Removed:      removed,
+						Left:         left,
+						Signature:    signature,
+					},
+				}
+				if err != nil {
+					ev.Type = EventFailed
+				}
+				return ev
+			}
+		}
+	}
+
+	opts := job.Options
+	fallback := ""
+	nextFallback := 0
+	retries := 0
+
+	var initial, final int64
+	var err error
+	attempt := 0
+	for {
+		attempt++
+		initial, final, err = p.attempt(id, target, opts, start, session)
+		if err == nil || p.ctx.Err() != nil {
+			break
+		}
+
+		// Decide between retrying the same settings and the next fallback
+		next := ""
+		if compression.IsTransient(err) && retries < job.Retry.MaxRetries {
+			retries++
+		} else if nextFallback < len(job.Retry.Fallbacks) {
+			fb := job.Retry.Fallbacks[nextFallback]
+			nextFallback++
+			opts = fb.Options
+			opts.SourceHash = job.Options.SourceHash
+			fallback = fb.Name
+			next = fb.Name
+			retries = 0
+		} else {
+			break
+		}
+
+		now := time.Now()
+		p.events.push(Event{
+			Type:     EventRetrying,
+			Job:      job,
+			WorkerID: id,
+			Time:     now,
+			Elapsed:  now.Sub(start),
+			Attempt:  attempt,
+			Error:    err,
+			Fallback: next,
+		})
+
+		select {
+		case <-time.After(job.Retry.Delay):
+		case <-p.ctx.Done():
+		}
+		if p.ctx.Err() != nil {
+			break
+		}
+	}
+
+	if err == nil && c != nil {
+		c.Store(key, target.OutputPath, initial)
+	}
+	output := target.OutputPath
+	if err == nil {
+		var resolution Resolution
+		output, resolution, err = p.finishOutput(job, target.OutputPath, conflict, initial, final)
+		if resolution == Skipped {
+			return p.skipped(id, job, output, start)
+		}
+		if resolution != NoConflict {
+			conflict = resolution
+		}
+	}
+	notShrunk := false
+	var removed, left compression.ActiveContent
+	if err == nil {
+		removed, left = p.sanitized(job, output)
+		notShrunk, err = p.finishShrink(job, output, inputInfo, initial, final)
+	} else {
+		discard()
+	}
+
+	end := time.Now()
+	ev := Event{
+		Type:     EventFinished,
+		Job:      job,
+		WorkerID: id,
+		Time:     end,
+		Elapsed:  end.Sub(start),
+		Result: Result{
+			Job:          job,
+			OriginalSize: initial,
+			FinalSize:    final,
+			Duration:     end.Sub(start),
+			Error:        err,
+			Attempts:     attempt,
+			Fallback:     fallback,
+			OutputPath:   output,
+			Conflict:     conflict,
+			NotShrunk:    notShrunk,
+			Removed:      removed,
+			Left:         left,
+			Signature:    signature,
+		},
+	}
+	if err != nil {
+		ev.Type = EventFailed
+		if p.ctx.Err() != nil {
+			ev.Type = EventCancelled
+		}
+	}
+	return ev
+}
+
+// finishOutput moves a compressed output to its final name. An output
+// written to a scratch file (conflict is Overwrote) replaces the existing
+// file. Otherwise {pages} and {ratio} are filled in and job's conflict
+// policy is applied to the final name; a Skipped output is removed again.
+func (p *Pool) finishOutput(job Job, output string, conflict Resolution, initial, final int64) (string, Resolution, error) {
+	if conflict == Overwrote {
+		if err := replaceOutput(output, job.OutputPath); err != nil {
+			return output, conflict, err
+		}
+		return job.OutputPath, conflict, nil
+	}
+	if !naming.Pending(output) {
+		return output, NoConflict, nil
+	}
+	outcome := naming.Outcome{OriginalSize: initial, FinalSize: final}
+	if naming.NeedsPages(output) {
+		pages, err := compression.PageCount(p.ctx, output)
+		if err != nil {
+			return output, NoConflict, err
+		}
+		outcome.Pages = pages
+	}
+
+	named, conflict := p.resolveConflict(job, naming.Resolve(output, outcome))
+	if conflict == Skipped {
+		os.Remove(output)
+		return named, Skipped, nil
+	}
+	if conflict == Overwrote {
+		if err := replaceOutput(output, named); err != nil {
+			return output, NoConflict, err
+		}
+		return named, conflict, nil
+	}
+	if err := os.Rename(output, named); err != nil {
+		return output, NoConflict, fmt.Errorf("failed to rename output file: %w", err)
+	}
+	return named, conflict, nil
+}
+
+// finishShrink applies job's shrink policy to output and gives the file
+// that is left the input's attributes as they were in inputInfo. This
+// covers restored cache entries and originals copied over the output.
+func (p *Pool) finishShrink(job Job, output string, inputInfo os.FileInfo, initial, final int64) (bool, error) {
+	notShrunk, size, err := ApplyShrinkPolicy(job.Shrink, job.InputPath, output, initial, final)
+	if err != nil || size == 0 {
+		return notShrunk, err
+	}
+	return notShrunk, compression.PreserveAttributes(job.InputPath, output, inputInfo, job.Options)
+}
+
+// sanitized returns what sanitizing job took out of its input and what it
+// left in, by comparing it with output. Files that cannot be inspected
+// count nothing.
+func (p *Pool) sanitized(job Job, output string) (removed, left compression.ActiveContent) {
+	if !job.Options.Sanitize.Enabled() {
+		return compression.ActiveContent{}, compression.ActiveContent{}
+	}
+	removed, left, _ = compression.RemovedContent(p.ctx, job.InputPath, output, job.Options.Sanitize)
+	return removed, left
+}
+
+// skipped is the terminal event of a job whose existing output was kept
+func (p *Pool) skipped(id int, job Job, existing string, start time.Time) Event {
+	end := time.Now()
+	res := Result{
+		Job:        job,
+		Duration:   end.Sub(start),
+		OutputPath: existing,
+		Conflict:   Skipped,
+	}
+	if info, err := os.Stat(job.InputPath); err == nil {
+		res.OriginalSize = info.Size()
+	}
+	if info, err := os.Stat(existing); err == nil {
+		res.FinalSize = info.Size()
+	}
+	return Event{
+		Type:     EventFinished,
+		Job:      job,
+		WorkerID: id,
+		Time:     end,
+		Elapsed:  end.Sub(start),
+		Result:   res,
+	}
+}
+
+// failed is the terminal event of a job that could not be started
+func (p *Pool) failed(id int, job Job, err error, start time.Time) Event {
+	end := time.Now()
+	res := Result{Job: job, Duration: end.Sub(start), Error: err}
+	if info, statErr := os.Stat(job.InputPath); statErr == nil {
+		res.OriginalSize = info.Size()
+	}
+	return Event{
+		Type:     EventFailed,
+		Job:      job,
+		WorkerID: id,
+		Time:     end,
+		Elapsed:  end.Sub(start),
+		Result:   res,
+	}
+}
+
+// attempt runs Ghostscript (or the engine in opts) once for job
+func (p *Pool) attempt(id int, job Job, opts compression.CompressionOptions, start time.Time, session *compression.Session) (int64, int64, error) {
+	if size := inputSize(job); job.Split.applies(size) {
+		extra := slots{
+			take: func() bool { return p.borrow(size) },
+			give: func() { p.giveBack(size) },
+		}
+		return compressSplit(p.ctx, job.InputPath, job.OutputPath, opts, job.Split, extra, p.tracked(compression.CompressPDFWithHooks), nil)
+	}
+
+	hooks := compression.Hooks{
+		OnPage: func(page, total int) {
+			now := time.Now()
+			p.events.push(Event{
+				Type:     EventProgress,
+				Job:      job,
+				WorkerID: id,
+				Time:     now,
+				Elapsed:  now.Sub(start),
+				Page:     page,
+				Pages:    total,
+			})
+		},
+	}
+	compress := compression.CompressPDFWithHooks
+	if p.reusing() {
+		compress = session.Compress
+	}
+	return p.tracked(compress)(p.ctx, job.InputPath, job.OutputPath, opts, hooks)
+}
+
+// tracked wraps compress so that the pool can suspend and kill the
+// processes it starts
+func (p *Pool) tracked(compress compressFunc) compressFunc {
+	return func(ctx context.Context, inputPath, outputPath string, opts compression.CompressionOptions, hooks compression.Hooks) (int64, int64, error) {
+		var proc *os.Process
+		hooks.OnStart = func(started *os.Process) {
+			proc = started
+			p.track(proc)
+		}
+		initial, final, err := compress(ctx, inputPath, outputPath, opts, hooks)
+		if proc != nil {
+			p.untrack(proc)
+		}
+		return initial, final, err
+	}
+}
+
+func (p *Pool) track(proc *os.Process) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running[proc] = struct{}{}
+	// Started just as the pool was suspended
+	if p.suspended {
+		compression.SuspendProcess(proc)
+	}
+}
+
+func (p *Pool) untrack(proc *os.Process) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.running, proc)
+}
+
+// next blocks until a job may start according to the schedule and
+// memory budget. It returns false when the calling worker should exit
+// (pool shrunk, or closed and drained).
+func (p *Pool) next(id int) (queuedJob, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		if p.workers > p.size {
+			p.retire(id)
+			return queuedJob{}, false
+		}
+		if !p.paused && p.busy+p.borrowed < p.size {
+			if i := p.pick(); i >= 0 {
+				q := p.queue[i]
+				p.queue = append(p.queue[:i], p.queue[i+1:]...)
+				p.inFlight += q.size
+				p.busy++
+				return q, true
+			}
+		}
+		if p.closed && len(p.queue) == 0 {
+			p.retire(id)
+			if p.workers == 0 {
+				// Last worker out: no more events can be sent
+				p.drained = true
+				p.events.close()
+				close(p.done)
+				p.cancel()
+			}
+			return queuedJob{}, false
+		}
+		p.cond.Wait()
+	}
+}
+
+func (p *Pool) retire(id int) {
+	p.workers--
+	delete(p.ids, id)
 }
 
 // RunPool processes a list of jobs using a specified number of concurrent workers
 // It returns a channel that streams results as they complete.
 func RunPool(jobs []Job, numWorkers int) <-chan Result {
-	jobChan := make(chan Job, len(jobs))
-	resultChan := make(chan Result, len(jobs))
-	var wg sync.WaitGroup
-
-	// Fill job channel
+	p := NewPool(numWorkers)
 	for _, job := range jobs {
-		jobChan <- job
-	}
-	close(jobChan)
-
-	// Start workers
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for job := range jobChan {
-				initial, final, err := compression.CompressPDF(job.InputPath, job.OutputPath, job.Options)
-				resultChan <- Result{
-					Job:          job,
-					OriginalSize: initial,
-					FinalSize:    final,
-					Error:        err,
-				}
-			}
-		}()
+		p.Submit(job)
 	}
+	p.Close()
 
-	// Wait and close results in a separate goroutine
+	results := make(chan Result, len(jobs))
 	go func() {
-		wg.Wait()
-		close(resultChan)
+		defer close(results)
+		for ev := range p.Events() {
+			if ev.Terminal() {
+				results <- ev.Result
+			}
+		}
 	}()
-
-	return resultChan
+	return results
 }
@@ -0,0 +1,189 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeOutput is the fake gs command that writes a small PDF to the
+// -sOutputFile path
+const writeOutput = `for a; do case $a in -sOutputFile=*) printf '%%PDF-1.4\n%%%%EOF\n' > "${a#-sOutputFile=}";; esac; done
+`
+
+// fakeGhostscript puts a gs running script on PATH and returns its folder
+func fakeGhostscript(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake gs is a shell script")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "gs"), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+// isolateTrash sends files the test replaces or deletes to a private trash
+func isolateTrash(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// runJob runs job on a new pool, set up by setup, and returns its result
+func runJob(t *testing.T, job Job, setup ...func(*Pool)) Result {
+	t.Helper()
+	p := NewPool(1)
+	for _, f := range setup {
+		f(p)
+	}
+	if err := p.Submit(job); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+
+	var res *Result
+	for ev := range p.Events() {
+		if ev.Terminal() {
+			res = &ev.Result
+		}
+	}
+	if res == nil {
+		t.Fatal("the job did not finish")
+	}
+	return *res
+}
+
+// leftovers lists the temporary outputs left in dir
+func leftovers(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".spc-out-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestPoolOverwrite(t *testing.T) {
+	isolateTrash(t)
+	fakeGhostscript(t, writeOutput)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	output := filepath.Join(dir, "out.pdf")
+	writeFile(t, input, "%PDF-1.4 input, larger than the output\n%%EOF\n")
+	writeFile(t, output, "existing")
+
+	res := runJob(t, Job{InputPath: input, OutputPath: output, Conflict: ConflictOverwrite})
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if res.Conflict != Overwrote || res.OutputPath != output {
+		t.Errorf("Conflict %s, OutputPath %s, want overwrote, %s", res.Conflict, res.OutputPath, output)
+	}
+	if got := readFile(t, output); !strings.HasPrefix(got, "%PDF-") {
+		t.Errorf("output = %q, want the compressed PDF", got)
+	}
+	if left := leftovers(t, dir); len(left) > 0 {
+		t.Errorf("temporary outputs left behind: %v", left)
+	}
+}
+
+// A failed job must leave the file it would have replaced alone
+func TestPoolOverwriteFailureKeepsExisting(t *testing.T) {
+	isolateTrash(t)
+	fakeGhostscript(t, writeOutput+"exit 1\n")
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	output := filepath.Join(dir, "out.pdf")
+	writeFile(t, input, "%PDF-1.4\n%%EOF\n")
+	writeFile(t, output, "existing")
+
+	res := runJob(t, Job{InputPath: input, OutputPath: output, Conflict: ConflictOverwrite})
+	if res.Error == nil {
+		t.Fatal("the job succeeded, want the gs failure")
+	}
+	if got := readFile(t, output); got != "existing" {
+		t.Errorf("existing output = %q, want it unchanged", got)
+	}
+	if left := leftovers(t, dir); len(left) > 0 {
+		t.Errorf("temporary outputs left behind: %v", left)
+	}
+}
+
+func TestPoolRename(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	output := filepath.Join(dir, "out.pdf")
+	renamed := filepath.Join(dir, "out (2).pdf")
+	writeFile(t, input, "%PDF-1.4\n%%EOF\n")
+	writeFile(t, output, "existing")
+
+	t.Run("success", func(t *testing.T) {
+		fakeGhostscript(t, writeOutput)
+		res := runJob(t, Job{InputPath: input, OutputPath: output, Conflict: ConflictRename})
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		if res.Conflict != Renamed || res.OutputPath != renamed {
+			t.Errorf("Conflict %s, OutputPath %s, want renamed, %s", res.Conflict, res.OutputPath, renamed)
+		}
+		if got := readFile(t, output); got != "existing" {
+			t.Errorf("existing output = %q, want it unchanged", got)
+		}
+		os.Remove(renamed)
+	})
+
+	// The reserved name is released again
+	t.Run("failure", func(t *testing.T) {
+		fakeGhostscript(t, "exit 1\n")
+		res := runJob(t, Job{InputPath: input, OutputPath: output, Conflict: ConflictRename})
+		if res.Error == nil {
+			t.Fatal("the job succeeded, want the gs failure")
+		}
+		if _, err := os.Stat(renamed); !os.IsNotExist(err) {
+			t.Errorf("reserved output %s was left behind", renamed)
+		}
+	})
+}
+
+func TestPoolSkipExisting(t *testing.T) {
+	bin := fakeGhostscript(t, `touch "$(dirname "$0")/ran"`+"\n")
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	output := filepath.Join(dir, "out.pdf")
+	writeFile(t, input, "%PDF-1.4\n%%EOF\n")
+	writeFile(t, output, "existing")
+
+	res := runJob(t, Job{InputPath: input, OutputPath: output, Conflict: ConflictSkip})
+	if res.Error != nil || res.Conflict != Skipped {
+		t.Errorf("Error %v, Conflict %s, want nil, skipped", res.Error, res.Conflict)
+	}
+	if res.FinalSize != int64(len("existing")) {
+		t.Errorf("FinalSize = %d, want the existing file's size", res.FinalSize)
+	}
+	if _, err := os.Stat(filepath.Join(bin, "ran")); err == nil {
+		t.Error("gs ran for a skipped job")
+	}
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"time"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+)
+
+// RetryPolicy controls what the pool does when a job fails
+type RetryPolicy struct {
+	// MaxRetries is how often the same settings are retried when the
+	// error is transient (see compression.IsTransient). The count starts
+	// over for each fallback.
+	MaxRetries int
+	// Delay is the pause before each retry
+	Delay time.Duration
+	// Fallbacks are tried in order once the retries are used up or the
+	// error is not transient
+	Fallbacks []Fallback
+}
+
+// Fallback is an alternative set of options for a failed job
+type Fallback struct {
+	Name    string
+	Options compression.CompressionOptions
+}
+
+// DefaultRetryPolicy retries transient failures twice and then walks
+// through DefaultFallbacks for opts.
+func DefaultRetryPolicy(opts compression.CompressionOptions) RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 2,
+		Delay:      time.Second,
+		Fallbacks:  DefaultFallbacks(opts),
+	}
+}
+
+// DefaultFallbacks returns increasingly conservative variants of opts,
+// ending with a lossless qpdf pass that works on most files Ghostscript
+// cannot handle. The qpdf pass is left out when opts sanitize the file
+// or strip or edit its metadata, which qpdf outputs would keep.
+func DefaultFallbacks(opts compression.CompressionOptions) []Fallback {
+	noDuplicates := opts
+	noDuplicates.NoDuplicateImageDetection = true
+
+	lowerLevel := noDuplicates
+	lowerLevel.CompatibilityLevel = "1.3"
+
+	repaired := noDuplicates
+	repaired.Repair = true
+
+	lossless := opts
+	lossless.Engine = compression.EngineQPDF
+
+	fallbacks := []Fallback{
+		{Name: "without duplicate image detection", Options: noDuplicates},
+		{Name: "PDF 1.3 compatibility", Options: lowerLevel},
+		{Name: "repair pass first", Options: repaired},
+	}
+	if !opts.RequiresGhostscript() {
+		fallbacks = append(fallbacks, Fallback{Name: "lossless qpdf", Options: lossless})
+	}
+	return fallbacks
+}
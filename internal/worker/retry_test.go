@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+)
+
+func TestDefaultFallbacks(t *testing.T) {
+	opts := compression.CompressionOptions{Quality: "ebook"}
+	fallbacks := DefaultFallbacks(opts)
+	if len(fallbacks) != 4 {
+		t.Fatalf("got %d fallbacks, want 4", len(fallbacks))
+	}
+	for _, fb := range fallbacks[:3] {
+		if fb.Options.Quality != "ebook" {
+			t.Errorf("%s: quality %q, want the job's", fb.Name, fb.Options.Quality)
+		}
+	}
+	if !fallbacks[0].Options.NoDuplicateImageDetection || fallbacks[1].Options.CompatibilityLevel != "1.3" || !fallbacks[2].Options.Repair {
+		t.Errorf("fallbacks do not change the expected settings: %+v", fallbacks)
+	}
+	if fallbacks[3].Options.Engine != compression.EngineQPDF {
+		t.Errorf("last fallback uses %q, want qpdf", fallbacks[3].Options.Engine)
+	}
+
+	// qpdf would keep what the job removes or edits
+	opts.Sanitize.JavaScript = true
+	for _, fb := range DefaultFallbacks(opts) {
+		if fb.Options.Engine == compression.EngineQPDF {
+			t.Errorf("sanitizing job falls back to qpdf")
+		}
+	}
+}
+
+func TestPoolRetry(t *testing.T) {
+	fallbacks := []Fallback{
+		{Name: "first", Options: compression.CompressionOptions{Quality: "printer"}},
+		{Name: "second", Options: compression.CompressionOptions{Quality: "screen"}},
+	}
+	tests := []struct {
+		name         string
+		script       string
+		retry        RetryPolicy
+		wantErr      bool
+		wantAttempts int
+		wantFallback string
+	}{
+		{
+			name:         "transient failure retried",
+			script:       `[ -f "$(dirname "$0")/runs" ] || { echo "Error: /VMerror"; echo run > "$(dirname "$0")/runs"; exit 1; }` + "\n" + writeOutput,
+			retry:        RetryPolicy{MaxRetries: 2},
+			wantAttempts: 2,
+		},
+		{
+			name:         "falls back",
+			script:       `case "$*" in *PDFSETTINGS=/screen*) ;; *) exit 1;; esac` + "\n" + writeOutput,
+			retry:        RetryPolicy{MaxRetries: 2, Fallbacks: fallbacks},
+			wantAttempts: 3,
+			wantFallback: "second",
+		},
+		{
+			name:         "runs out of fallbacks",
+			script:       "exit 1\n",
+			retry:        RetryPolicy{Fallbacks: fallbacks},
+			wantErr:      true,
+			wantAttempts: 3,
+			wantFallback: "second",
+		},
+		{
+			name:         "no retry policy",
+			script:       "exit 1\n",
+			wantErr:      true,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeGhostscript(t, tt.script)
+			dir := t.TempDir()
+			input := filepath.Join(dir, "in.pdf")
+			writeFile(t, input, "%PDF-1.4\n%%EOF\n")
+
+			res := runJob(t, Job{InputPath: input, OutputPath: filepath.Join(dir, "out.pdf"), Retry: tt.retry})
+			if (res.Error != nil) != tt.wantErr {
+				t.Errorf("Error = %v, want error %v", res.Error, tt.wantErr)
+			}
+			if res.Attempts != tt.wantAttempts || res.Fallback != tt.wantFallback {
+				t.Errorf("Attempts %d, Fallback %q, want %d, %q", res.Attempts, res.Fallback, tt.wantAttempts, tt.wantFallback)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package worker
+
+import "os"
+
+// Schedule decides which queued job a free worker picks next
+type Schedule int
+
+const (
+	// ScheduleFIFO runs jobs in the order they were submitted
+	ScheduleFIFO Schedule = iota
+	// ScheduleLargestFirst starts big inputs early so a large file added
+	// last does not leave the other workers idle at the end of a batch
+	ScheduleLargestFirst
+	// ScheduleShortestFirst runs small inputs (short jobs) first
+	ScheduleShortestFirst
+)
+
+func (s Schedule) String() string {
+	switch s {
+	case ScheduleFIFO:
+		return "fifo"
+	case ScheduleLargestFirst:
+		return "largest-first"
+	case ScheduleShortestFirst:
+		return "shortest-first"
+	}
+	return "unknown"
+}
+
+// queuedJob is a submitted job with its input size, measured once at Submit
+type queuedJob struct {
+	job       Job
+	size      int64
+	overtaken int // jobs started while this one waited for budget
+}
+
+// maxOvertakes is how many jobs may start ahead of one waiting for memory
+// budget. After that no other job starts until it fits, so that a large
+// job is not held back forever while small ones keep being submitted.
+const maxOvertakes = 4
+
+func inputSize(job Job) int64 {
+	info, err := os.Stat(job.InputPath)
+	if err != nil {
+		// Let the job fail normally when it runs
+		return 0
+	}
+	return info.Size()
+}
+
+// SetSchedule changes the order in which queued jobs are started
+func (p *Pool) SetSchedule(s Schedule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.schedule = s
+}
+
+// SetMemoryBudget limits the total input size of jobs running at the
+// same time (0 disables the limit). A job larger than the budget still
+// runs, but only when nothing else is in flight.
+func (p *Pool) SetMemoryBudget(bytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.budget = bytes
+	p.cond.Broadcast()
+}
+
+// pick returns the index of the queued job to start next, or -1 if none
+// fits the memory budget right now. Jobs that fit may overtake a job
+// that is waiting for budget, up to maxOvertakes times.
+func (p *Pool) pick() int {
+	best := -1
+	for i, q := range p.queue {
+		if !p.fits(q.size) {
+			if q.overtaken >= maxOvertakes {
+				// Let the running jobs drain until it fits
+				return -1
+			}
+			continue
+		}
+		if best < 0 {
+			best = i
+			continue
+		}
+		switch p.schedule {
+		case ScheduleLargestFirst:
+			if q.size > p.queue[best].size {
+				best = i
+			}
+		case ScheduleShortestFirst:
+			if q.size < p.queue[best].size {
+				best = i
+			}
+		}
+	}
+	if best < 0 {
+		return -1
+	}
+	for i := range p.queue {
+		if i != best && !p.fits(p.queue[i].size) {
+			p.queue[i].overtaken++
+		}
+	}
+	return best
+}
+
+func (p *Pool) fits(size int64) bool {
+	return p.budget <= 0 || p.inFlight == 0 || p.inFlight+size <= p.budget
+}
+
+// release returns a finished job's worker and bytes to the budget
+func (p *Pool) release(size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight -= size
+	p.busy--
+	p.cond.Broadcast()
+}
+
+// borrow lends an idle worker to a part of a split job, which reads an
+// input of size bytes. Queued jobs go first, and the part must fit the
+// memory budget next to the jobs already running.
+func (p *Pool) borrow(size int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused || len(p.queue) > 0 || p.busy+p.borrowed >= p.size {
+		return false
+	}
+	if p.budget > 0 && p.inFlight+size > p.budget {
+		return false
+	}
+	p.borrowed++
+	p.inFlight += size
+	return true
+}
+
+// giveBack returns a worker lent by borrow
+func (p *Pool) giveBack(size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.borrowed--
+	p.inFlight -= size
+	p.cond.Broadcast()
+}
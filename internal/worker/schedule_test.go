@@ -0,0 +1,79 @@
+package worker
+
+import "testing"
+
+func queued(sizes ...int64) []queuedJob {
+	var q []queuedJob
+	for _, size := range sizes {
+		q = append(q, queuedJob{size: size})
+	}
+	return q
+}
+
+func TestPickOrder(t *testing.T) {
+	tests := []struct {
+		schedule Schedule
+		want     int
+	}{
+		{ScheduleFIFO, 0},
+		{ScheduleLargestFirst, 1},
+		{ScheduleShortestFirst, 2},
+	}
+	for _, tt := range tests {
+		p := &Pool{schedule: tt.schedule, queue: queued(20, 50, 10, 30)}
+		if got := p.pick(); got != tt.want {
+			t.Errorf("%s: pick = %d, want %d", tt.schedule, got, tt.want)
+		}
+	}
+}
+
+func TestPickBudget(t *testing.T) {
+	p := &Pool{budget: 100, inFlight: 60, queue: queued(50, 30, 50)}
+	if got := p.pick(); got != 1 {
+		t.Errorf("pick = %d, want 1, the only job that fits", got)
+	}
+
+	// Nothing fits until the running jobs finish
+	p = &Pool{budget: 100, inFlight: 90, queue: queued(50, 30)}
+	if got := p.pick(); got != -1 {
+		t.Errorf("pick = %d, want -1", got)
+	}
+
+	// A job larger than the budget runs once nothing else does
+	p = &Pool{budget: 100, queue: queued(500)}
+	if got := p.pick(); got != 0 {
+		t.Errorf("pick = %d, want 0", got)
+	}
+}
+
+// A large job must start even while small jobs keep being submitted
+func TestPickDoesNotStarveLargeJobs(t *testing.T) {
+	const budget, small, large = 100, 30, 150
+	p := &Pool{budget: budget, queue: queued(small, large, small, small)}
+	var running []int64
+	for step := 0; step < 50; step++ {
+		for {
+			i := p.pick()
+			if i < 0 {
+				break
+			}
+			q := p.queue[i]
+			p.queue = append(p.queue[:i], p.queue[i+1:]...)
+			if q.size == large {
+				if q.overtaken > maxOvertakes {
+					t.Errorf("large job overtaken %d times, want at most %d", q.overtaken, maxOvertakes)
+				}
+				return
+			}
+			p.inFlight += q.size
+			running = append(running, q.size)
+		}
+		// One job finishes and another small one is submitted
+		if len(running) > 0 {
+			p.inFlight -= running[0]
+			running = running[1:]
+		}
+		p.queue = append(p.queue, queuedJob{size: small})
+	}
+	t.Fatal("the large job never started")
+}
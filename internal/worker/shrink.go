@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"fmt"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/fileops"
+)
+
+// ShrinkAction is what happens to an output that did not get small enough
+type ShrinkAction int
+
+const (
+	ShrinkKeep         ShrinkAction = iota // keep the output as is
+	ShrinkDelete                           // move the output to the trash
+	ShrinkCopyOriginal                     // replace the output with a copy of the input (not for sanitized jobs)
+)
+
+func (a ShrinkAction) String() string {
+	switch a {
+	case ShrinkKeep:
+		return "kept"
+	case ShrinkDelete:
+		return "deleted"
+	case ShrinkCopyOriginal:
+		return "replaced by the original"
+	}
+	return "unknown"
+}
+
+// ShrinkPolicy handles outputs that saved less than MinSavings percent
+// of the input size. The zero value keeps every output and only flags
+// those that did not shrink at all.
+type ShrinkPolicy struct {
+	MinSavings float64 // percent, e.g. 5 keeps only outputs at least 5% smaller
+	Action     ShrinkAction
+}
+
+// Insufficient reports whether going from original to final bytes saves
+// less than the policy requires
+func (p ShrinkPolicy) Insufficient(original, final int64) bool {
+	if original <= 0 {
+		return false
+	}
+	savings := (1 - float64(final)/float64(original)) * 100
+	if p.MinSavings <= 0 {
+		return final >= original
+	}
+	return savings < p.MinSavings
+}
+
+// ApplyShrinkPolicy applies policy to the output compressed from input.
+// It reports whether the output saved too little and the output size
+// afterwards (0 if it was deleted).
+func ApplyShrinkPolicy(policy ShrinkPolicy, input, output string, original, final int64) (bool, int64, error) {
+	if !policy.Insufficient(original, final) {
+		return false, final, nil
+	}
+	switch policy.Action {
+	case ShrinkDelete:
+		if _, err := fileops.Remove(output); err != nil {
+			return true, final, fmt.Errorf("failed to delete output file: %w", err)
+		}
+		return true, 0, nil
+	case ShrinkCopyOriginal:
+		if err := fileops.CopyFile(input, output); err != nil {
+			return true, final, fmt.Errorf("failed to copy original file: %w", err)
+		}
+		return true, original, nil
+	}
+	return true, final, nil
+}
@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInsufficient(t *testing.T) {
+	tests := []struct {
+		minSavings      float64
+		original, final int64
+		want            bool
+	}{
+		{0, 100, 99, false},
+		{0, 100, 100, true},
+		{0, 100, 120, true},
+		{5, 100, 95, false},
+		{5, 100, 96, true},
+		{50, 1000, 400, false},
+		{50, 1000, 600, true},
+		{5, 0, 10, false}, // nothing to compare with
+	}
+	for _, tt := range tests {
+		policy := ShrinkPolicy{MinSavings: tt.minSavings}
+		if got := policy.Insufficient(tt.original, tt.final); got != tt.want {
+			t.Errorf("MinSavings %v: Insufficient(%d, %d) = %v, want %v", tt.minSavings, tt.original, tt.final, got, tt.want)
+		}
+	}
+}
+
+func TestApplyShrinkPolicy(t *testing.T) {
+	const input, compressed = "original input", "smaller"
+	tests := []struct {
+		name          string
+		policy        ShrinkPolicy
+		final         int64
+		wantNotShrunk bool
+		wantSize      int64
+		wantOutput    string // "" if the output is gone
+	}{
+		{"enough savings", ShrinkPolicy{MinSavings: 10, Action: ShrinkDelete}, 7, false, 7, compressed},
+		{"keep", ShrinkPolicy{MinSavings: 90, Action: ShrinkKeep}, 7, true, 7, compressed},
+		{"delete", ShrinkPolicy{MinSavings: 90, Action: ShrinkDelete}, 7, true, 0, ""},
+		{"copy original", ShrinkPolicy{MinSavings: 90, Action: ShrinkCopyOriginal}, 7, true, int64(len(input)), input},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateTrash(t)
+			dir := t.TempDir()
+			in := filepath.Join(dir, "in.pdf")
+			out := filepath.Join(dir, "out.pdf")
+			writeFile(t, in, input)
+			writeFile(t, out, compressed)
+
+			notShrunk, size, err := ApplyShrinkPolicy(tt.policy, in, out, int64(len(input)), tt.final)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if notShrunk != tt.wantNotShrunk || size != tt.wantSize {
+				t.Errorf("ApplyShrinkPolicy = %v, %d, want %v, %d", notShrunk, size, tt.wantNotShrunk, tt.wantSize)
+			}
+			if tt.wantOutput == "" {
+				if _, err := os.Stat(out); !os.IsNotExist(err) {
+					t.Errorf("output was not deleted: %v", err)
+				}
+				return
+			}
+			if got := readFile(t, out); got != tt.wantOutput {
+				t.Errorf("output = %q, want %q", got, tt.wantOutput)
+			}
+		})
+	}
+}
+
+// Sanitized outputs are never replaced by their original
+func TestPoolShrinkSanitizedKeepsOutput(t *testing.T) {
+	fakeGhostscript(t, writeOutput)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	output := filepath.Join(dir, "out.pdf")
+	writeFile(t, input, "%PDF-1.4\n%%EOF\n")
+
+	job := Job{InputPath: input, OutputPath: output, Shrink: ShrinkPolicy{MinSavings: 50, Action: ShrinkCopyOriginal}}
+	job.Options.Sanitize.JavaScript = true
+	res := runJob(t, job)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if !res.NotShrunk || res.Job.Shrink.Action != ShrinkKeep {
+		t.Errorf("NotShrunk %v, action %s, want true, kept", res.NotShrunk, res.Job.Shrink.Action)
+	}
+}
@@ -0,0 +1,80 @@
+package worker
+
+import "context"
+
+// SignaturePolicy decides what happens to digitally signed inputs, whose
+// signatures do not survive compression
+type SignaturePolicy int
+
+const (
+	SignatureSkip     SignaturePolicy = iota // leave signed files alone
+	SignatureAsk                             // call the pool's ConfirmSignedFunc
+	SignatureCompress                        // compress them anyway
+)
+
+func (s SignaturePolicy) String() string {
+	switch s {
+	case SignatureSkip:
+		return "skip"
+	case SignatureAsk:
+		return "ask"
+	case SignatureCompress:
+		return "compress"
+	}
+	return "unknown"
+}
+
+// SignatureHandling records how a job's input was treated
+type SignatureHandling int
+
+const (
+	NotSigned        SignatureHandling = iota // the input has no signature
+	SignedSkipped                             // the input was signed and not compressed
+	SignedCompressed                          // the input was signed and compressed anyway
+)
+
+func (s SignatureHandling) String() string {
+	switch s {
+	case NotSigned:
+		return "not signed"
+	case SignedSkipped:
+		return "skipped"
+	case SignedCompressed:
+		return "compressed"
+	}
+	return "unknown"
+}
+
+// ConfirmSignedFunc is called for jobs with SignatureAsk whose input is
+// signed. It returns true to compress the file anyway. Calls are made one
+// at a time, from the worker handling the job; ctx is handled like
+// AskFunc's.
+type ConfirmSignedFunc func(ctx context.Context, job Job) bool
+
+// SetConfirmSignedFunc sets the function deciding SignatureAsk jobs.
+// Without one, signed inputs of such jobs are skipped.
+func (p *Pool) SetConfirmSignedFunc(confirm ConfirmSignedFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.confirmSigned = confirm
+}
+
+// checkSignature applies job's signature policy to its input, which is
+// signed or not (see compression.DigestFile)
+func (p *Pool) checkSignature(job Job, signed bool) SignatureHandling {
+	if !signed {
+		return NotSigned
+	}
+	switch job.Signature {
+	case SignatureCompress:
+		return SignedCompressed
+	case SignatureAsk:
+		p.mu.Lock()
+		confirm := p.confirmSigned
+		p.mu.Unlock()
+		if confirm != nil && prompt(p, func(ctx context.Context) bool { return confirm(ctx, job) }, false) {
+			return SignedCompressed
+		}
+	}
+	return SignedSkipped
+}
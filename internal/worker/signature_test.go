@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/testutil"
+)
+
+func TestCheckSignature(t *testing.T) {
+	confirm := func(answer bool) ConfirmSignedFunc {
+		return func(ctx context.Context, job Job) bool { return answer }
+	}
+	tests := []struct {
+		name    string
+		signed  bool
+		policy  SignaturePolicy
+		confirm ConfirmSignedFunc
+		want    SignatureHandling
+	}{
+		{"unsigned", false, SignatureSkip, nil, NotSigned},
+		{"skip", true, SignatureSkip, nil, SignedSkipped},
+		{"compress", true, SignatureCompress, nil, SignedCompressed},
+		{"ask without ConfirmSignedFunc", true, SignatureAsk, nil, SignedSkipped},
+		{"ask and confirm", true, SignatureAsk, confirm(true), SignedCompressed},
+		{"ask and decline", true, SignatureAsk, confirm(false), SignedSkipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newIdlePool(t)
+			p.SetConfirmSignedFunc(tt.confirm)
+			if got := p.checkSignature(Job{Signature: tt.policy}, tt.signed); got != tt.want {
+				t.Errorf("checkSignature = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolSkipsSigned(t *testing.T) {
+	fakeGhostscript(t, writeOutput)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "signed.pdf")
+	output := filepath.Join(dir, "out.pdf")
+	writeFile(t, input, testutil.SignedPDF)
+
+	res := runJob(t, Job{InputPath: input, OutputPath: output, Signature: SignatureSkip})
+	if res.Error != nil || res.Signature != SignedSkipped {
+		t.Errorf("Error %v, Signature %s, want nil, skipped", res.Error, res.Signature)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("a skipped signed input was compressed")
+	}
+
+	res = runJob(t, Job{InputPath: input, OutputPath: output, Signature: SignatureCompress})
+	if res.Error != nil || res.Signature != SignedCompressed {
+		t.Errorf("Error %v, Signature %s, want nil, compressed", res.Error, res.Signature)
+	}
+	if _, err := os.Stat(output); err != nil {
+		t.Errorf("signed input was not compressed: %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+)
+
+// Defaults for splitting large files
+const (
+	DefaultSplitThreshold = 50 << 20 // 50 MB
+	DefaultMinChunkPages  = 10
+)
+
+// SplitOptions controls intra-file parallelism for large inputs. Set on
+// a Job, the parts run on the job's worker and on workers of the pool
+// that are idle, within its memory budget.
+//
+// Links whose target page lies in another chunk are lost: each chunk is
+// compressed as a separate document, so such links have no destination
+// by the time the parts are merged. Bookmarks are not affected, they are
+// rebuilt from the original outline.
+type SplitOptions struct {
+	// Threshold is the input size from which a file is split
+	Threshold int64
+	// Workers is the largest number of chunks (fewer than 2 disables
+	// splitting)
+	Workers int
+	// MinPages is the smallest chunk worth a separate Ghostscript run
+	MinPages int
+}
+
+// applies reports whether an input of size bytes is split
+func (s SplitOptions) applies(size int64) bool {
+	return s.Workers >= 2 && size >= s.Threshold
+}
+
+// compressFunc compresses one file, like compression.CompressPDFWithHooks
+type compressFunc func(ctx context.Context, inputPath, outputPath string, opts compression.CompressionOptions, hooks compression.Hooks) (int64, int64, error)
+
+// slots hands out extra workers for the parts of a split input: take
+// reports whether one more part may run at the same time, give returns
+// the worker once it is done.
+type slots struct {
+	take func() bool
+	give func()
+}
+
+// CompressSplit compresses one PDF using several cores: inputs at or
+// above split.Threshold are cut into page ranges, up to split.Workers
+// ranges are compressed in parallel and the parts are merged back into
+// outputPath. Bookmarks are restored when qpdf is available to read
+// them; links survive within each range. Smaller inputs are compressed
+// in one piece. onProgress, if set, receives the number of finished
+// parts. opts.Timeout limits the whole operation, merge included.
+func CompressSplit(ctx context.Context, inputPath, outputPath string, opts compression.CompressionOptions, split SplitOptions, onProgress func(done, total int)) (int64, int64, error) {
+	var mu sync.Mutex
+	running := 1 // the caller's own
+	extra := slots{
+		take: func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			if running >= split.Workers {
+				return false
+			}
+			running++
+			return true
+		},
+		give: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			running--
+		},
+	}
+	return compressSplit(ctx, inputPath, outputPath, opts, split, extra, compression.CompressPDFWithHooks, onProgress)
+}
+
+// compressSplit runs CompressSplit with compress for each part. The
+// caller runs parts itself and takes workers from extra while parts are
+// left to run beside it.
+func compressSplit(ctx context.Context, inputPath, outputPath string, opts compression.CompressionOptions, split SplitOptions, extra slots, compress compressFunc, onProgress func(done, total int)) (int64, int64, error) {
+	if split.MinPages < 1 {
+		split.MinPages = DefaultMinChunkPages
+	}
+
+	// 1. Decide whether splitting is worth it
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to stat input file: %w", err)
+	}
+	initialSize := info.Size()
+	inputInfo := info
+	if !split.applies(initialSize) {
+		return compress(ctx, inputPath, outputPath, opts, compression.Hooks{})
+	}
+
+	// The timeout covers counting, every part and the merge; the parts
+	// run without a timeout of their own
+	parent := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	timedOut := func(err error) error {
+		if opts.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+			return fmt.Errorf("%w after %s", compression.ErrTimeout, opts.Timeout)
+		}
+		return err
+	}
+
+	pages, err := compression.PageCount(ctx, inputPath)
+	if err != nil {
+		return initialSize, 0, timedOut(err)
+	}
+	ranges := compression.SplitPages(pages, split.Workers, split.MinPages)
+	if len(ranges) < 2 {
+		return compress(ctx, inputPath, outputPath, opts, compression.Hooks{})
+	}
+
+	// 2. Compress the page ranges in parallel
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return initialSize, 0, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(outputPath), ".spc-split-")
+	if err != nil {
+		return initialSize, 0, fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	partsCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parts := make([]string, len(ranges))
+	queue := make(chan int, len(ranges))
+	for i := range ranges {
+		parts[i] = filepath.Join(tmpDir, fmt.Sprintf("part-%04d.pdf", i))
+		queue <- i
+	}
+	close(queue)
+
+	var mu sync.Mutex
+	done := 0
+	var firstErr error
+	runPart := func(i int) {
+		// A failed part kills the others; the remaining ones are skipped
+		if partsCtx.Err() != nil {
+			return
+		}
+		partOpts := opts
+		partOpts.FirstPage = ranges[i].First
+		partOpts.LastPage = ranges[i].Last
+		partOpts.Timeout = 0
+		// The merged file is stamped and gets the dates and attributes instead
+		partOpts.Stamp, partOpts.PreserveDates = false, false
+		partOpts.PreserveTimes, partOpts.PreserveMode, partOpts.PreserveXattrs = false, false, false
+		_, _, err := compress(partsCtx, inputPath, parts[i], partOpts, compression.Hooks{})
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("pages %d-%d: %w", ranges[i].First, ranges[i].Last, err)
+			cancel()
+		}
+		done++
+		if onProgress != nil {
+			onProgress(done, len(ranges))
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range queue {
+		for len(queue) > 0 && extra.take() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer extra.give()
+				for i := range queue {
+					runPart(i)
+				}
+			}()
+		}
+		runPart(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return initialSize, 0, timedOut(firstErr)
+	}
+
+	// 3. Merge the parts, restoring bookmarks from the original
+	outline, err := compression.ReadOutline(ctx, inputPath)
+	if err != nil {
+		outline = nil // bookmarks are best effort
+	}
+	if err := compression.MergePDFs(ctx, parts, outputPath, outline, inputPath, opts); err != nil {
+		return initialSize, 0, timedOut(err)
+	}
+
+	info, err = os.Stat(outputPath)
+	if err != nil {
+		return initialSize, 0, fmt.Errorf("failed to stat output file: %w", err)
+	}
+	if err := compression.PreserveAttributes(inputPath, outputPath, inputInfo, opts); err != nil {
+		return initialSize, info.Size(), err
+	}
+	return initialSize, info.Size(), nil
+}
@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+)
+
+// splitScript is a fake gs for a 40 page input that logs "+" and "-"
+// around each part it compresses and runs merge after merging
+func splitScript(merge string) string {
+	return `case "$*" in *-dNODISPLAY*) echo 40; exit 0;; esac
+log="$(dirname "$0")/log"
+case "$*" in
+*-dFirstPage=*) echo + >> "$log"; sleep 0.2; echo - >> "$log";;
+*) ` + merge + `;;
+esac
+` + writeOutput
+}
+
+// maxParallel returns the most parts that ran at once according to log
+func maxParallel(log string) int {
+	running, most := 0, 0
+	for _, line := range strings.Fields(log) {
+		if line == "+" {
+			running++
+			most = max(most, running)
+		} else {
+			running--
+		}
+	}
+	return most
+}
+
+func TestPoolSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+		budget  int64
+		want    int
+	}{
+		{"one worker runs the parts in turn", 1, 0, 1},
+		{"idle workers help", 3, 0, 3},
+		{"budget limits the helpers", 3, 2 * 1024, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := fakeGhostscript(t, splitScript(":"))
+			dir := t.TempDir()
+			input := filepath.Join(dir, "in.pdf")
+			writeFile(t, input, strings.Repeat("x", 1024))
+
+			job := Job{
+				InputPath:  input,
+				OutputPath: filepath.Join(dir, "out.pdf"),
+				Split:      SplitOptions{Workers: 4},
+			}
+			res := runJob(t, job, func(p *Pool) {
+				p.Resize(tt.workers)
+				p.SetMemoryBudget(tt.budget)
+			})
+			if res.Error != nil {
+				t.Fatal(res.Error)
+			}
+			log := readFile(t, filepath.Join(gs, "log"))
+			if got := strings.Count(log, "+"); got != 4 {
+				t.Errorf("compressed %d parts, want 4", got)
+			}
+			if got := maxParallel(log); got != tt.want {
+				t.Errorf("%d parts ran at once, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// The timeout covers the merge, not only each part
+func TestCompressSplitTimeout(t *testing.T) {
+	fakeGhostscript(t, splitScript("exec sleep 5"))
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeFile(t, input, "%PDF-1.4\n%%EOF\n")
+
+	opts := compression.CompressionOptions{Timeout: time.Second}
+	start := time.Now()
+	_, _, err := CompressSplit(context.Background(), input, filepath.Join(dir, "out.pdf"), opts, SplitOptions{Workers: 2}, nil)
+	if !errors.Is(err, compression.ErrTimeout) {
+		t.Errorf("CompressSplit = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("CompressSplit took %s, want it stopped after the timeout", elapsed)
+	}
+}
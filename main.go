@@ -1,21 +1,42 @@
 package main
 
 import (
+	"context"
 	_ "embed"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
-	"simplepdfcompress/internal/ui"
 	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/system"
+	"github.com/thelaonerd/simplepdfcompress/internal/ui"
+	"github.com/thelaonerd/simplepdfcompress/internal/web"
 )
 
 //go:embed icon.png
 var iconData []byte
 
 func main() {
+	serveAddr := flag.String("serve", "", "run headless and serve the web UI on this address (e.g. 8080 or 127.0.0.1:8080; a bare port listens on localhost only)")
+	threads := flag.Int("threads", runtime.NumCPU(), "maximum number of concurrent compressions in server mode")
+	maxUpload := flag.Int64("max-upload", web.DefaultMaxUpload>>20, "maximum size of one upload in MB in server mode")
+	flag.Parse()
+
+	if *serveAddr != "" {
+		if err := runServer(*serveAddr, *threads, *maxUpload<<20); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	a := app.NewWithID("com.simplepdfcompress.app")
 
 	// Attempt to set system font (Linux/fontconfig)
@@ -45,3 +66,24 @@ func main() {
 
 	w.ShowAndRun()
 }
+
+// runServer runs the browser UI without opening a window (headless/SSH use)
+func runServer(addr string, threads int, maxUpload int64) error {
+	checks := system.PerformChecks()
+	if !checks.IsReady {
+		return errors.New(checks.Message)
+	}
+
+	srv, err := web.NewServer(threads, maxUpload)
+	if err != nil {
+		return err
+	}
+	defer srv.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	addr = web.ListenAddr(addr)
+	fmt.Printf("Serving SimplePDFCompress on http://%s (Ctrl+C to stop)\n", addr)
+	return srv.ListenAndServe(ctx, addr)
+}
@@ -0,0 +1,82 @@
+package spc
+
+import "github.com/thelaonerd/simplepdfcompress/internal/compression"
+
+// MetadataMode decides what happens to the input's document info and XMP
+// metadata in Ghostscript outputs. qpdf outputs keep them unchanged.
+type MetadataMode int
+
+const (
+	// MetadataDefault keeps the Info entries except Producer and the
+	// dates, which Ghostscript replaces, and rebuilds XMP from them
+	MetadataDefault MetadataMode = iota
+	// MetadataPreserve also keeps the input's Producer, Creator and
+	// dates. Only the Info dictionary is copied: XMP is rebuilt from it,
+	// so XMP properties without an Info entry are lost.
+	MetadataPreserve
+	// MetadataStrip removes the Info entries, XMP and the document ID
+	MetadataStrip
+)
+
+func (m MetadataMode) String() string {
+	return compression.MetadataMode(m).String()
+}
+
+// FormAction is what Sanitize does with interactive form fields
+type FormAction int
+
+const (
+	FormsKeep    FormAction = iota // leave forms fillable
+	FormsRemove                    // drop the fields and their contents
+	FormsFlatten                   // print the filled-in fields into the page
+)
+
+// Sanitize selects content Ghostscript leaves out of the output. The zero
+// value removes nothing.
+type Sanitize struct {
+	// JavaScript removes the document's open action and named scripts.
+	// Scripts run by links and form fields are kept with them; they go
+	// only with Annotations or Forms, and Result.Left counts those left.
+	JavaScript bool
+	// EmbeddedFiles removes attachments, including file attachment
+	// annotations
+	EmbeddedFiles bool
+	// Forms keeps, removes or flattens form fields
+	Forms FormAction
+	// Annotations removes comments, highlights, links and other markup
+	Annotations bool
+	// Thumbnails removes page thumbnail images
+	Thumbnails bool
+}
+
+func (s Sanitize) internal() compression.Sanitize {
+	return compression.Sanitize{
+		JavaScript:    s.JavaScript,
+		EmbeddedFiles: s.EmbeddedFiles,
+		Forms:         compression.FormAction(s.Forms),
+		Annotations:   s.Annotations,
+		Thumbnails:    s.Thumbnails,
+	}
+}
+
+// ActiveContent counts scripts, attachments, form fields, annotations and
+// thumbnails in a PDF. Result.Removed uses it for what sanitizing took out,
+// Result.Left for what it was asked to but could not.
+type ActiveContent struct {
+	JavaScript    int // JavaScript actions
+	OpenActions   int // actions run when the document opens
+	EmbeddedFiles int
+	FormFields    int
+	Annotations   int // annotations other than form fields
+	Thumbnails    int
+}
+
+// IsZero reports whether nothing was counted
+func (c ActiveContent) IsZero() bool {
+	return c == ActiveContent{}
+}
+
+// String lists the non-zero counts, e.g. "2 scripts, 1 attachment"
+func (c ActiveContent) String() string {
+	return compression.ActiveContent(c).String()
+}
@@ -0,0 +1,55 @@
+// Command batch compresses the PDFs in a folder tree on a worker pool.
+//
+//	go run ./pkg/spc/examples/batch [-workers 4] folder
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/thelaonerd/simplepdfcompress/pkg/spc"
+)
+
+func main() {
+	workers := flag.Int("workers", runtime.NumCPU(), "number of files compressed at once")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: batch [-workers n] folder")
+		os.Exit(2)
+	}
+	dir := flag.Arg(0)
+
+	// Skip earlier outputs so they are not compressed twice
+	found, err := spc.ScanFolder(dir, spc.ScanOptions{
+		SkipHidden:     true,
+		SkipOutputDirs: true,
+		Suffix:         spc.DefaultSuffix,
+		SkipOwnOutputs: true,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	engine := spc.NewGhostscript(spc.Options{Quality: spc.QualityEbook, Retry: true})
+	pool := spc.NewPool(engine, *workers)
+	for _, input := range found.Files {
+		pool.Submit(input, spc.OutputPath(input, "", ""))
+	}
+	pool.Close()
+
+	failed := 0
+	for res := range pool.Results() {
+		if res.Err != nil {
+			failed++
+			fmt.Printf("[X] %s: %v\n", res.Input, res.Err)
+			continue
+		}
+		fmt.Printf("[O] %s: %.1f%%\n", res.Input, res.Ratio())
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,41 @@
+// Command compress compresses one PDF with the spc package.
+//
+//	go run ./pkg/spc/examples/compress [-quality ebook] input.pdf [output.pdf]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/thelaonerd/simplepdfcompress/pkg/spc"
+)
+
+func main() {
+	quality := flag.String("quality", string(spc.QualityEbook), "screen, ebook, printer, prepress or default")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: compress [-quality q] input.pdf [output.pdf]")
+		os.Exit(2)
+	}
+
+	input := flag.Arg(0)
+	output := flag.Arg(1)
+	if output == "" {
+		output = spc.OutputPath(input, "", "")
+	}
+
+	engine := spc.NewGhostscript(spc.Options{Quality: spc.Quality(*quality)})
+	if err := engine.Check(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	res, err := engine.CompressFile(context.Background(), input, output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: %d -> %d bytes (%.1f%%) in %s\n", res.Output, res.OriginalSize, res.FinalSize, res.Ratio(), res.Duration)
+}
@@ -0,0 +1,22 @@
+// Command stream compresses a PDF from stdin to stdout.
+//
+//	go run ./pkg/spc/examples/stream < input.pdf > output.pdf
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/thelaonerd/simplepdfcompress/pkg/spc"
+)
+
+func main() {
+	engine := spc.NewGhostscript(spc.Options{Quality: spc.QualityEbook})
+	res, err := engine.CompressStream(context.Background(), os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "%d -> %d bytes (%.1f%%)\n", res.OriginalSize, res.FinalSize, res.Ratio())
+}
@@ -0,0 +1,122 @@
+package spc
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/naming"
+)
+
+// DefaultSuffix is appended to output file names when no suffix is given
+const DefaultSuffix = "_spc_compressed"
+
+// OutputPath returns where the compressed copy of input is written:
+// dir/<name><suffix>.pdf, or a "compressed" folder next to input when
+// dir is empty.
+func OutputPath(input, dir, suffix string) string {
+	return outputName(OutputDir(input, "", dir), input, suffix)
+}
+
+// Ratio returns the size reduction from original to final in percent.
+// It is negative when the file grew.
+func Ratio(original, final int64) float64 {
+	if original == 0 {
+		return 0.0
+	}
+	return (1.0 - (float64(final) / float64(original))) * 100.0
+}
+
+// MirroredOutputPath is like OutputPath but recreates the folder of input
+// relative to root under dir, so files with the same name in different
+// folders do not clash
+func MirroredOutputPath(input, root, dir, suffix string) string {
+	return outputName(OutputDir(input, root, dir), input, suffix)
+}
+
+// OutputDir returns the folder an output of input goes to: dir, or a
+// "compressed" folder next to input when dir is empty. With root set, the
+// folder of input relative to root is recreated under dir; inputs outside
+// root go to dir itself.
+func OutputDir(input, root, dir string) string {
+	if dir == "" {
+		return filepath.Join(filepath.Dir(input), "compressed")
+	}
+	if root == "" {
+		return dir
+	}
+	rel, err := filepath.Rel(root, filepath.Dir(input))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return dir
+	}
+	return filepath.Join(dir, rel)
+}
+
+func outputName(dir, input, suffix string) string {
+	if suffix == "" {
+		suffix = DefaultSuffix
+	}
+	baseName := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	return filepath.Join(dir, fmt.Sprintf("%s%s.pdf", baseName, suffix))
+}
+
+// Collisions groups the indexes of outputs that name the same file. Names
+// are compared case insensitively on Windows and macOS, whose file
+// systems usually are.
+func Collisions(outputs []string) [][]int {
+	byKey := make(map[string][]int)
+	var keys []string
+	for i, out := range outputs {
+		key := pathKey(out)
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], i)
+	}
+
+	var clashes [][]int
+	for _, key := range keys {
+		if len(byKey[key]) > 1 {
+			clashes = append(clashes, byKey[key])
+		}
+	}
+	return clashes
+}
+
+// Dedupe returns outputs with every repeated name after the first given a
+// number, e.g. "report_spc_compressed (2).pdf"
+func Dedupe(outputs []string) []string {
+	result := make([]string, len(outputs))
+	taken := make(map[string]bool, len(outputs))
+	for _, out := range outputs {
+		taken[pathKey(out)] = true
+	}
+
+	seen := make(map[string]bool, len(outputs))
+	for i, out := range outputs {
+		key := pathKey(out)
+		if !seen[key] {
+			seen[key] = true
+			result[i] = out
+			continue
+		}
+		for n := 2; ; n++ {
+			candidate := naming.Numbered(out, n)
+			if !taken[pathKey(candidate)] {
+				taken[pathKey(candidate)] = true
+				result[i] = candidate
+				break
+			}
+		}
+	}
+	return result
+}
+
+func pathKey(p string) string {
+	p = filepath.Clean(p)
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		p = strings.ToLower(p)
+	}
+	return p
+}
@@ -0,0 +1,94 @@
+package spc_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/thelaonerd/simplepdfcompress/pkg/spc"
+)
+
+func TestOutputPath(t *testing.T) {
+	input := filepath.Join("docs", "report.pdf")
+	tests := []struct {
+		dir, suffix, want string
+	}{
+		{"", "", filepath.Join("docs", "compressed", "report_spc_compressed.pdf")},
+		{"out", "", filepath.Join("out", "report_spc_compressed.pdf")},
+		{"out", "_small", filepath.Join("out", "report_small.pdf")},
+	}
+	for _, tt := range tests {
+		if got := spc.OutputPath(input, tt.dir, tt.suffix); got != tt.want {
+			t.Errorf("OutputPath(%q, %q, %q) = %q, want %q", input, tt.dir, tt.suffix, got, tt.want)
+		}
+	}
+}
+
+func TestMirroredOutputPath(t *testing.T) {
+	root := filepath.Join("data", "in")
+	tests := []struct {
+		input, want string
+	}{
+		{filepath.Join(root, "a.pdf"), filepath.Join("out", "a_x.pdf")},
+		{filepath.Join(root, "sub", "deep", "a.pdf"), filepath.Join("out", "sub", "deep", "a_x.pdf")},
+		// Outside root: straight into the output folder
+		{filepath.Join("data", "other", "a.pdf"), filepath.Join("out", "a_x.pdf")},
+	}
+	for _, tt := range tests {
+		if got := spc.MirroredOutputPath(tt.input, root, "out", "_x"); got != tt.want {
+			t.Errorf("MirroredOutputPath(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	// Without an output folder it behaves like OutputPath
+	input := filepath.Join(root, "sub", "a.pdf")
+	if got, want := spc.MirroredOutputPath(input, root, "", "_x"), spc.OutputPath(input, "", "_x"); got != want {
+		t.Errorf("MirroredOutputPath without dir = %q, want %q", got, want)
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	outputs := []string{
+		filepath.Join("out", "a.pdf"),
+		filepath.Join("out", "b.pdf"),
+		filepath.Join("out", ".", "a.pdf"),
+		filepath.Join("out", "c.pdf"),
+		filepath.Join("out", "b.pdf"),
+	}
+	want := [][]int{{0, 2}, {1, 4}}
+	if got := spc.Collisions(outputs); !reflect.DeepEqual(got, want) {
+		t.Errorf("Collisions = %v, want %v", got, want)
+	}
+	if got := spc.Collisions(outputs[:2]); got != nil {
+		t.Errorf("Collisions without clashes = %v, want nil", got)
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	a := filepath.Join("out", "a.pdf")
+	a2 := filepath.Join("out", "a (2).pdf")
+	a3 := filepath.Join("out", "a (3).pdf")
+	b := filepath.Join("out", "b.pdf")
+
+	// a (2).pdf is taken by another input, so the repeat becomes a (3).pdf
+	got := spc.Dedupe([]string{a, b, a, a2})
+	want := []string{a, b, a3, a2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Dedupe = %v, want %v", got, want)
+	}
+	if len(spc.Collisions(got)) != 0 {
+		t.Errorf("Dedupe left clashes in %v", got)
+	}
+}
+
+func TestRatio(t *testing.T) {
+	if got := spc.Ratio(200, 50); got != 75 {
+		t.Errorf("Ratio(200, 50) = %v, want 75", got)
+	}
+	if got := spc.Ratio(100, 150); got != -50 {
+		t.Errorf("Ratio(100, 150) = %v, want -50", got)
+	}
+	if got := spc.Ratio(0, 10); got != 0 {
+		t.Errorf("Ratio(0, 10) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,93 @@
+package spc
+
+import (
+	"context"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/worker"
+)
+
+// ErrPoolClosed is returned by Submit after Close or Shutdown
+var ErrPoolClosed = worker.ErrPoolClosed
+
+// Pool compresses files concurrently with one Engine
+type Pool struct {
+	engine  *Engine
+	pool    *worker.Pool
+	results chan Result
+}
+
+// NewPool starts a pool of workers (at least one) compressing with engine
+func NewPool(engine *Engine, workers int) *Pool {
+	p := &Pool{
+		engine:  engine,
+		pool:    worker.NewPool(workers),
+		results: make(chan Result),
+	}
+	go p.collect()
+	return p
+}
+
+// Submit queues input to be compressed into output
+func (p *Pool) Submit(input, output string) error {
+	job := worker.Job{InputPath: input, OutputPath: output, Options: p.engine.opts}
+	if p.engine.compressSigned {
+		job.Signature = worker.SignatureCompress
+	}
+	if p.engine.retry {
+		job.Retry = worker.DefaultRetryPolicy(job.Options)
+	}
+	return p.pool.Submit(job)
+}
+
+// Results delivers one Result per submitted file, in completion order.
+// It must be drained; it is closed after Close once every file is done.
+func (p *Pool) Results() <-chan Result {
+	return p.results
+}
+
+// Resize changes the number of workers without interrupting running files
+func (p *Pool) Resize(workers int) {
+	p.pool.Resize(workers)
+}
+
+// SetReuseProcesses keeps one Ghostscript interpreter per worker instead
+// of starting a process per file, which is faster for many small files
+func (p *Pool) SetReuseProcesses(on bool) {
+	p.pool.SetReuseProcesses(on)
+}
+
+// Close stops accepting files; queued files are still compressed
+func (p *Pool) Close() {
+	p.pool.Close()
+}
+
+// Shutdown closes the pool and waits for queued files. If ctx expires
+// first, the remaining files are cancelled and ctx.Err() is returned.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	return p.pool.Shutdown(ctx)
+}
+
+func (p *Pool) collect() {
+	defer close(p.results)
+	for ev := range p.pool.Events() {
+		if !ev.Terminal() {
+			continue
+		}
+		r := ev.Result
+		if r.Signature == worker.SignedSkipped {
+			r.Error = ErrSigned
+		}
+		p.results <- Result{
+			Input:        r.Job.InputPath,
+			Output:       r.OutputPath,
+			OriginalSize: r.OriginalSize,
+			FinalSize:    r.FinalSize,
+			Duration:     r.Duration,
+			Attempts:     r.Attempts,
+			Fallback:     r.Fallback,
+			Removed:      ActiveContent(r.Removed),
+			Left:         ActiveContent(r.Left),
+			Err:          r.Error,
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package spc
+
+import (
+	"context"
+	"time"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/scan"
+)
+
+// ScanOptions filters the files found by ScanFolder. The zero value
+// matches every "*.pdf" file at any depth.
+type ScanOptions struct {
+	// Include lists glob patterns a file name must match. Patterns
+	// without a slash match the file name, patterns with one match the
+	// path relative to the scanned folder. Matching is case insensitive.
+	// If empty, every file is a candidate with DetectContent and "*.pdf"
+	// is used otherwise.
+	Include []string
+	// Exclude lists patterns for files and folders to leave out; a
+	// matching folder is not entered
+	Exclude []string
+	// DetectContent recognises PDFs by their %PDF- header instead of
+	// trusting the file extension
+	DetectContent bool
+	// MaxDepth limits how deep folders are entered (0 = unlimited,
+	// 1 = only the files directly in the folder)
+	MaxDepth int
+	// SkipHidden leaves out files and folders starting with a dot
+	SkipHidden bool
+	// SkipOutputDirs leaves out "compressed" folders and OutputDirs
+	SkipOutputDirs bool
+	OutputDirs     []string
+	// MinSize and MaxSize bound the file size in bytes (0 = no bound)
+	MinSize int64
+	MaxSize int64
+	// ModifiedSince leaves out files last modified before it (zero = any)
+	ModifiedSince time.Time
+	// FollowSymlinks enters linked folders and includes linked files;
+	// otherwise symlinks are ignored
+	FollowSymlinks bool
+	// Suffix marks outputs of this package (see SkipOwnOutputs)
+	Suffix string
+	// SkipOwnOutputs leaves out files whose name ends in Suffix or that
+	// carry a Stamp. Outputs written with MetadataStrip have no stamp, so
+	// they are only recognised by their name.
+	SkipOwnOutputs bool
+}
+
+// ScanResult lists the files ScanFolder matched, sorted by path
+type ScanResult struct {
+	Files      []string
+	Filtered   int // files left out by the filters
+	Skipped    int // outputs of this package left out by SkipOwnOutputs
+	Unreadable []ScanError
+}
+
+// ScanError is a file or folder ScanFolder could not read
+type ScanError struct {
+	Path string
+	Err  error
+}
+
+// ScanFolder walks root and returns the files that pass opts. Folders and
+// files that cannot be read are listed in ScanResult.Unreadable; only an
+// unreadable root is an error.
+func ScanFolder(root string, opts ScanOptions) (ScanResult, error) {
+	return ScanFolderContext(context.Background(), root, opts, nil)
+}
+
+// ScanFolderContext is like ScanFolder but stops when ctx is cancelled,
+// returning the files found so far with ctx.Err(). onProgress, if not
+// nil, is called periodically with the number of PDFs found and folders
+// read.
+func ScanFolderContext(ctx context.Context, root string, opts ScanOptions, onProgress func(found, dirs int)) (ScanResult, error) {
+	s := scan.Scanner{Options: scan.Options(opts), OnProgress: onProgress}
+	res, err := s.Scan(ctx, root)
+
+	out := ScanResult{Files: res.Files, Filtered: res.Filtered, Skipped: res.Skipped}
+	for _, e := range res.Unreadable {
+		out.Unreadable = append(out.Unreadable, ScanError(e))
+	}
+	return out, err
+}
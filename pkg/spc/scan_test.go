@@ -0,0 +1,130 @@
+package spc_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/thelaonerd/simplepdfcompress/pkg/spc"
+)
+
+// makeTree creates files (slash-separated paths relative to root) with a
+// PDF header, or text for names not ending in .pdf
+func makeTree(t *testing.T, files ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, name := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		data := "%PDF-1.4\n%%EOF\n"
+		if filepath.Ext(name) == ".txt" {
+			data = "not a pdf"
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func relFiles(t *testing.T, root string, res spc.ScanResult) []string {
+	t.Helper()
+	var rel []string
+	for _, f := range res.Files {
+		r, err := filepath.Rel(root, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	return rel
+}
+
+func TestScanFolder(t *testing.T) {
+	root := makeTree(t,
+		"a.pdf",
+		"B.PDF",
+		"notes.txt",
+		"sub/c.pdf",
+		"sub/deeper/d.pdf",
+		".hidden/e.pdf",
+		"compressed/a_spc_compressed.pdf",
+		"f_spc_compressed.pdf",
+	)
+
+	tests := []struct {
+		name string
+		opts spc.ScanOptions
+		want []string
+	}{
+		{"all", spc.ScanOptions{}, []string{
+			".hidden/e.pdf", "B.PDF", "a.pdf", "compressed/a_spc_compressed.pdf",
+			"f_spc_compressed.pdf", "sub/c.pdf", "sub/deeper/d.pdf",
+		}},
+		{"filtered", spc.ScanOptions{
+			SkipHidden:     true,
+			SkipOutputDirs: true,
+			Suffix:         spc.DefaultSuffix,
+			SkipOwnOutputs: true,
+		}, []string{"B.PDF", "a.pdf", "sub/c.pdf", "sub/deeper/d.pdf"}},
+		{"depth", spc.ScanOptions{MaxDepth: 2, SkipHidden: true, SkipOutputDirs: true}, []string{
+			"B.PDF", "a.pdf", "f_spc_compressed.pdf", "sub/c.pdf",
+		}},
+		{"include", spc.ScanOptions{Include: []string{"sub/*.pdf"}}, []string{"sub/c.pdf"}},
+		{"exclude", spc.ScanOptions{Exclude: []string{"sub", ".*", "*_spc_compressed.pdf"}}, []string{
+			"B.PDF", "a.pdf",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := spc.ScanFolder(root, tt.opts)
+			if err != nil {
+				t.Fatalf("ScanFolder: %v", err)
+			}
+			if got := relFiles(t, root, res); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanFolderCounts(t *testing.T) {
+	root := makeTree(t, "a.pdf", "notes.txt", "a_spc_compressed.pdf")
+	res, err := spc.ScanFolder(root, spc.ScanOptions{Suffix: spc.DefaultSuffix, SkipOwnOutputs: true})
+	if err != nil {
+		t.Fatalf("ScanFolder: %v", err)
+	}
+	if got := relFiles(t, root, res); !reflect.DeepEqual(got, []string{"a.pdf"}) {
+		t.Errorf("files = %v, want [a.pdf]", got)
+	}
+	if res.Skipped != 1 {
+		t.Errorf("Skipped = %d, want 1", res.Skipped)
+	}
+
+	// By content: the text file is still not a PDF
+	res, err = spc.ScanFolder(root, spc.ScanOptions{DetectContent: true})
+	if err != nil {
+		t.Fatalf("ScanFolder: %v", err)
+	}
+	if len(res.Files) != 2 {
+		t.Errorf("DetectContent found %v, want the two PDFs", res.Files)
+	}
+}
+
+func TestScanFolderErrors(t *testing.T) {
+	if _, err := spc.ScanFolder(filepath.Join(t.TempDir(), "missing"), spc.ScanOptions{}); err == nil {
+		t.Error("expected an error for a missing root")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	root := makeTree(t, "a.pdf")
+	if _, err := spc.ScanFolderContext(ctx, root, spc.ScanOptions{}, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled scan returned %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,225 @@
+// Package spc is the public API of SimplePDFCompress. It compresses PDF
+// files with Ghostscript (lossy) or qpdf (lossless), one at a time or on
+// a pool of workers, using the same compression code as the desktop and
+// web front ends.
+//
+// The engines run the external gs and qpdf tools, which must be
+// installed; Engine.Check reports whether they are.
+package spc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/compression"
+)
+
+// Quality is a Ghostscript preset, trading file size for image quality
+type Quality string
+
+const (
+	QualityScreen   Quality = "screen"   // 72 dpi images, smallest files
+	QualityEbook    Quality = "ebook"    // 150 dpi images
+	QualityPrinter  Quality = "printer"  // 300 dpi images
+	QualityPrepress Quality = "prepress" // 300 dpi images, colour preserving
+	QualityDefault  Quality = "default"  // Ghostscript's general-purpose settings
+)
+
+// Options configures an Engine. The zero value uses Ghostscript's own
+// defaults and no limits.
+type Options struct {
+	// Quality is the Ghostscript preset (ignored by qpdf)
+	Quality Quality
+	// CompatibilityLevel is the PDF version written by Ghostscript ("" means 1.4)
+	CompatibilityLevel string
+
+	// Timeout stops a file that takes longer (0 = no limit)
+	Timeout time.Duration
+	// MaxMemory limits the memory of each Ghostscript process in bytes (0 = no limit)
+	MaxMemory int64
+	// LowPriority runs the tools with lower CPU and IO priority
+	LowPriority bool
+	// Stamp records the preset and the input's SHA-256 in the output's
+	// document info (Ghostscript only), see ReadStamp
+	Stamp bool
+	// PreserveAttributes copies the input's modification and access time,
+	// permission bits and (on Linux) extended attributes and ACLs to the output
+	PreserveAttributes bool
+	// PreserveDates keeps the input's document creation and modification
+	// dates instead of the time of compression
+	PreserveDates bool
+	// Metadata decides what happens to the input's document info and XMP
+	// metadata (Ghostscript only). Stripped outputs are not stamped.
+	Metadata MetadataMode
+	// Title, Author, Subject and Keywords replace the document info
+	// entries when set (Ghostscript only)
+	Title, Author, Subject, Keywords string
+	// Sanitize removes scripts, attachments, forms, annotations and
+	// thumbnails (Ghostscript only); Result.Removed counts what went
+	Sanitize Sanitize
+	// CompressSigned compresses digitally signed inputs, invalidating
+	// their signatures. Without it they fail with ErrSigned.
+	CompressSigned bool
+
+	// Retry makes a Pool retry failed files, first with the same
+	// settings and then with increasingly conservative ones
+	Retry bool
+}
+
+// Result describes the outcome of compressing one file
+type Result struct {
+	Input        string
+	Output       string
+	OriginalSize int64
+	FinalSize    int64
+	Duration     time.Duration
+	// Attempts is the number of compression runs (more than one after retries)
+	Attempts int
+	// Fallback names the retry settings that produced the output, if any
+	Fallback string
+	// Removed counts what sanitizing took out of the file
+	Removed ActiveContent
+	// Left counts what sanitizing was asked to remove but the output
+	// still has, e.g. scripts of links and form fields that were kept
+	Left ActiveContent
+	Err  error
+}
+
+// Ratio returns the size reduction in percent (negative if the file grew)
+func (r Result) Ratio() float64 {
+	return Ratio(r.OriginalSize, r.FinalSize)
+}
+
+// ErrSigned is returned for digitally signed inputs unless
+// Options.CompressSigned is set
+var ErrSigned = compression.ErrSigned
+
+// Stamp is the provenance recorded in outputs when Options.Stamp is set
+type Stamp struct {
+	Preset     string // quality preset, or "lossless" for qpdf
+	SourceHash string // SHA-256 of the original input, hex encoded
+}
+
+// ReadStamp returns the stamp of a PDF compressed by this package, and
+// false if the file has none
+func ReadStamp(path string) (Stamp, bool, error) {
+	stamp, ok, err := compression.ReadStamp(path)
+	return Stamp(stamp), ok, err
+}
+
+// Engine compresses PDF files with one tool and set of options. It is
+// safe for concurrent use.
+type Engine struct {
+	opts           compression.CompressionOptions
+	retry          bool
+	compressSigned bool
+}
+
+// NewGhostscript returns an engine that recompresses files with
+// Ghostscript's pdfwrite device, downsampling images per opts.Quality.
+func NewGhostscript(opts Options) *Engine {
+	return newEngine(compression.EngineGhostscript, opts)
+}
+
+// NewQPDF returns an engine that recompresses streams losslessly with qpdf
+func NewQPDF(opts Options) *Engine {
+	return newEngine(compression.EngineQPDF, opts)
+}
+
+func newEngine(name string, opts Options) *Engine {
+	return &Engine{
+		opts: compression.CompressionOptions{
+			Quality:            string(opts.Quality),
+			Engine:             name,
+			CompatibilityLevel: opts.CompatibilityLevel,
+			Timeout:            opts.Timeout,
+			MaxMemory:          opts.MaxMemory,
+			LowPriority:        opts.LowPriority,
+			Stamp:              opts.Stamp,
+			PreserveTimes:      opts.PreserveAttributes,
+			PreserveMode:       opts.PreserveAttributes,
+			PreserveXattrs:     opts.PreserveAttributes,
+			PreserveDates:      opts.PreserveDates,
+			Metadata:           compression.MetadataMode(opts.Metadata),
+			Title:              opts.Title,
+			Author:             opts.Author,
+			Subject:            opts.Subject,
+			Keywords:           opts.Keywords,
+			Sanitize:           opts.Sanitize.internal(),
+		},
+		retry:          opts.Retry,
+		compressSigned: opts.CompressSigned,
+	}
+}
+
+// Name returns "gs" or "qpdf"
+func (e *Engine) Name() string {
+	return e.opts.Engine
+}
+
+// Check reports whether the engine's tool is installed
+func (e *Engine) Check() error {
+	bin := compression.GetGhostscriptCommand()
+	if e.opts.Engine == compression.EngineQPDF {
+		bin = compression.GetQPDFCommand()
+	}
+	if _, err := exec.LookPath(bin); err != nil {
+		return fmt.Errorf("%s is not installed: %w", bin, err)
+	}
+	return nil
+}
+
+// CompressFile compresses input into output, creating the output
+// directory if needed. Retries only apply to pools.
+func (e *Engine) CompressFile(ctx context.Context, input, output string) (Result, error) {
+	start := time.Now()
+	if !e.compressSigned {
+		if signed, _ := compression.IsSigned(input); signed {
+			return Result{Input: input, Output: output, Err: ErrSigned}, ErrSigned
+		}
+	}
+	initial, final, err := compression.CompressPDFContext(ctx, input, output, e.opts)
+	res := Result{
+		Input:        input,
+		Output:       output,
+		OriginalSize: initial,
+		FinalSize:    final,
+		Duration:     time.Since(start),
+		Attempts:     1,
+		Err:          err,
+	}
+	if err == nil && e.opts.Sanitize.Enabled() {
+		removed, left, _ := compression.RemovedContent(ctx, input, output, e.opts.Sanitize)
+		res.Removed = ActiveContent(removed)
+		res.Left = ActiveContent(left)
+	}
+	return res, err
+}
+
+// CompressStream compresses the PDF read from r and writes it to w.
+// Like CompressFile it fails with ErrSigned for signed inputs, writing
+// nothing, unless CompressSigned is set.
+func (e *Engine) CompressStream(ctx context.Context, r io.Reader, w io.Writer) (Result, error) {
+	start := time.Now()
+	var check func(string) error
+	if !e.compressSigned {
+		check = func(input string) error {
+			if signed, _ := compression.IsSigned(input); signed {
+				return ErrSigned
+			}
+			return nil
+		}
+	}
+	initial, final, err := compression.CompressStreamCheck(ctx, r, w, e.opts, check)
+	res := Result{
+		OriginalSize: initial,
+		FinalSize:    final,
+		Duration:     time.Since(start),
+		Attempts:     1,
+		Err:          err,
+	}
+	return res, err
+}
@@ -0,0 +1,188 @@
+package spc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thelaonerd/simplepdfcompress/internal/testutil"
+	"github.com/thelaonerd/simplepdfcompress/pkg/spc"
+)
+
+// writeSigned writes a file that looks digitally signed
+func writeSigned(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(testutil.SignedPDF), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ghostscript returns a Ghostscript engine, skipping the test if gs is
+// not installed
+func ghostscript(t *testing.T, opts spc.Options) *spc.Engine {
+	t.Helper()
+	engine := spc.NewGhostscript(opts)
+	if err := engine.Check(); err != nil {
+		t.Skip(err)
+	}
+	return engine
+}
+
+func TestCompressFile(t *testing.T) {
+	engine := ghostscript(t, spc.Options{Quality: spc.QualityEbook, Stamp: true})
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	output := filepath.Join(dir, "out", "in_small.pdf")
+	testutil.WritePDF(t, input, "Hello")
+
+	res, err := engine.CompressFile(context.Background(), input, output)
+	if err != nil {
+		t.Fatalf("CompressFile: %v", err)
+	}
+	if res.Input != input || res.Output != output || res.Err != nil || res.Attempts != 1 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatalf("output not written: %v", err)
+	}
+	if res.FinalSize != info.Size() {
+		t.Errorf("FinalSize = %d, output has %d bytes", res.FinalSize, info.Size())
+	}
+
+	stamp, ok, err := spc.ReadStamp(output)
+	if err != nil || !ok {
+		t.Fatalf("ReadStamp = %v, %v", ok, err)
+	}
+	if stamp.Preset != "ebook" || len(stamp.SourceHash) != 64 {
+		t.Errorf("unexpected stamp %+v", stamp)
+	}
+}
+
+func TestCompressFileSigned(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "signed.pdf")
+	output := filepath.Join(dir, "out.pdf")
+	writeSigned(t, input)
+
+	engine := spc.NewGhostscript(spc.Options{})
+	res, err := engine.CompressFile(context.Background(), input, output)
+	if !errors.Is(err, spc.ErrSigned) || !errors.Is(res.Err, spc.ErrSigned) {
+		t.Fatalf("err = %v, Result.Err = %v, want ErrSigned", err, res.Err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("output of a signed input was written")
+	}
+}
+
+func TestCompressFileMissingInput(t *testing.T) {
+	dir := t.TempDir()
+	engine := spc.NewGhostscript(spc.Options{})
+	res, err := engine.CompressFile(context.Background(), filepath.Join(dir, "missing.pdf"), filepath.Join(dir, "out.pdf"))
+	if err == nil || res.Err == nil {
+		t.Fatal("expected an error for a missing input")
+	}
+}
+
+func TestCompressStream(t *testing.T) {
+	engine := ghostscript(t, spc.Options{Quality: spc.QualityScreen})
+	input := filepath.Join(t.TempDir(), "in.pdf")
+	testutil.WritePDF(t, input, "Stream")
+	data, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	res, err := engine.CompressStream(context.Background(), bytes.NewReader(data), &out)
+	if err != nil {
+		t.Fatalf("CompressStream: %v", err)
+	}
+	if !bytes.HasPrefix(out.Bytes(), []byte("%PDF-")) {
+		t.Errorf("output is not a PDF")
+	}
+	if res.OriginalSize != int64(len(data)) || res.FinalSize != int64(out.Len()) {
+		t.Errorf("sizes %d -> %d, want %d -> %d", res.OriginalSize, res.FinalSize, len(data), out.Len())
+	}
+}
+
+func TestCompressStreamSigned(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "signed.pdf")
+	writeSigned(t, input)
+	data, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	res, err := spc.NewGhostscript(spc.Options{}).CompressStream(context.Background(), bytes.NewReader(data), &out)
+	if !errors.Is(err, spc.ErrSigned) || !errors.Is(res.Err, spc.ErrSigned) {
+		t.Fatalf("err = %v, Result.Err = %v, want ErrSigned", err, res.Err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("wrote %d bytes for a signed input", out.Len())
+	}
+}
+
+func TestPool(t *testing.T) {
+	dir := t.TempDir()
+	signed := filepath.Join(dir, "signed.pdf")
+	missing := filepath.Join(dir, "missing.pdf")
+	writeSigned(t, signed)
+
+	pool := spc.NewPool(spc.NewGhostscript(spc.Options{}), 2)
+	for _, input := range []string{signed, missing} {
+		if err := pool.Submit(input, spc.OutputPath(input, filepath.Join(dir, "out"), "")); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+	pool.Close()
+	if err := pool.Submit(signed, filepath.Join(dir, "late.pdf")); !errors.Is(err, spc.ErrPoolClosed) {
+		t.Errorf("Submit after Close = %v, want ErrPoolClosed", err)
+	}
+
+	results := make(map[string]spc.Result)
+	for res := range pool.Results() {
+		results[res.Input] = res
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if err := results[signed].Err; !errors.Is(err, spc.ErrSigned) {
+		t.Errorf("signed input: %v, want ErrSigned", err)
+	}
+	if results[missing].Err == nil {
+		t.Errorf("missing input did not fail")
+	}
+}
+
+func TestPoolCompresses(t *testing.T) {
+	engine := ghostscript(t, spc.Options{Quality: spc.QualityEbook})
+	dir := t.TempDir()
+	pool := spc.NewPool(engine, 2)
+	for i := range 3 {
+		input := filepath.Join(dir, fmt.Sprintf("file%d.pdf", i))
+		testutil.WritePDF(t, input, fmt.Sprintf("Page %d", i))
+		pool.Submit(input, spc.OutputPath(input, "", ""))
+	}
+	pool.Close()
+
+	n := 0
+	for res := range pool.Results() {
+		n++
+		if res.Err != nil {
+			t.Errorf("%s: %v", res.Input, res.Err)
+			continue
+		}
+		if _, err := os.Stat(res.Output); err != nil {
+			t.Errorf("%s: output missing: %v", res.Input, err)
+		}
+	}
+	if n != 3 {
+		t.Errorf("got %d results, want 3", n)
+	}
+}